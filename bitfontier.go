@@ -7,11 +7,17 @@ import (
 // Config contains all exported font generator options.
 type Config = fontgen.Config
 
+// RuneRange is an inclusive range of runes.
+type RuneRange = fontgen.RuneRange
+
 // MissingGlyphAction affects the code generated for the font package.
 //
 // Whether a font user tries to render a rune that is not present in the font,
 // some resolution strategy should be followed.
 // This enumeration provides such strategies.
+//
+// The measuring methods (GlyphAdvance and GlyphBounds) follow
+// the same strategy, so they're always consistent with Glyph.
 type MissingGlyphAction = fontgen.MissingGlyphAction
 
 const (
@@ -29,10 +35,58 @@ const (
 	// This mode is not recommended for production apps,
 	// but it can be useful when testing/debugging.
 	PanicOnMissingGlyph = fontgen.PanicOnMissingGlyph
+
+	// FallbackOnMissingGlyph resolves the missing runes to their closest
+	// glyphs during the font generation: the compatibility decomposition
+	// is used and the diacritics are stripped (so 'ｆ', 'ﬁ' and 'ǹ' are rendered as 'f', 'f' and 'n').
+	// These runes become the aliases inside the rune mapping, no new images are added.
+	// When the font is subsetted, only the selected runes get the fallbacks.
+	// The runes that can't be resolved are handled like in EmptyMaskOnMissingGlyph mode.
+	FallbackOnMissingGlyph = fontgen.FallbackOnMissingGlyph
+)
+
+// BitmapCodec is a glyph bitmap data encoding used in the generated package.
+type BitmapCodec = fontgen.BitmapCodec
+
+const (
+	// GzipCodec is the default encoding.
+	GzipCodec = fontgen.GzipCodec
+
+	// NoCodec stores the bitmap data as is.
+	// It's the fastest one to load, but it makes the binary bigger.
+	NoCodec = fontgen.NoCodec
+
+	// DeflateCodec is like GzipCodec, but without the gzip framing overhead.
+	DeflateCodec = fontgen.DeflateCodec
+
+	// RLECodec is a simple run-length encoding.
+	// Its decoder doesn't need any compress package.
+	RLECodec = fontgen.RLECodec
+)
+
+// MappingStrategy is a rune mapping implementation used in the generated package.
+type MappingStrategy = fontgen.MappingStrategy
+
+const (
+	// AutoMapping selects the strategy based on the mapping tables size.
+	AutoMapping = fontgen.AutoMapping
+
+	// RangeMapping is a binary search over the consecutive rune ranges.
+	// It's the most compact one for the sparse fonts.
+	RangeMapping = fontgen.RangeMapping
+
+	// LUTMapping is a dense lookup table, the fastest option for the small fonts.
+	LUTMapping = fontgen.LUTMapping
+
+	// PageTableMapping is a two-level lookup table suited for the big fonts.
+	PageTableMapping = fontgen.PageTableMapping
 )
 
 type GenerationResult = fontgen.GenerationResult
 
+// BitmapInfo describes the bitmap data of a generated font segment.
+type BitmapInfo = fontgen.BitmapInfo
+
 // Generate creates a bitmap font package following the
 // options specified in config.
 //
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -14,7 +16,13 @@ import (
 
 func main() {
 	var tagString string
+	var tagPriorityString string
+	var subsetRunesString string
+	var subsetFromString string
+	var composeString string
 	var onMissing string
+	var codec string
+	var mapping string
 	var debug bool
 	var generateDocs bool
 	var config bitfontier.Config
@@ -25,9 +33,59 @@ func main() {
 	flag.StringVar(&config.ResultPackage, "pkgname", "monofont",
 		"a result package name")
 	flag.StringVar(&tagString, "tags", "",
-		"a comma-separated list of tags to include into a result bundle;\nan empty value includes everything")
+		"a comma-separated list of tags to include into a result bundle;\nan empty value includes everything;\nunless --tag-priority is set, later tags override the runes of the earlier ones")
+	flag.StringVar(&tagPriorityString, "tag-priority", "",
+		"a comma-separated list of tags that can override each other runes;\nlater tags win")
+	flag.BoolVar(&config.SplitTags, "split-tags", false,
+		"whether to put every tag glyphs into separate files that can be excluded using `bitfont_no<tag>` build tags;\nimplies --tag-segments")
+	flag.BoolVar(&config.TagSegments, "tag-segments", false,
+		"whether to store every tag glyphs separately, so the font can be allocated with only some of its tags (see WithTags)")
+	flag.StringVar(&codec, "codec", "gzip",
+		"a bitmap data encoding (`gzip`, `deflate`, `rle`, or `none`);\nuse -v to compare their sizes")
+	flag.StringVar(&mapping, "mapping", "auto",
+		"a rune mapping strategy (`auto`, `ranges`, `lut`, or `pages`);\nuse -v to see the selected one")
+	flag.BoolVar(&config.SharedRuntime, "shared-runtime", false,
+		"whether to import the runtime from the bitfontier fontrt package instead of copying it into the result package;\nrequires --binary-tables, so it is enabled unless it is set to false explicitly")
+	flag.BoolVar(&config.CropGlyphs, "crop-glyphs", false,
+		"whether to store the glyph bitmaps cropped to their ink rectangles;\nuse -v to see the saved size")
+	flag.BoolVar(&config.BinaryTables, "binary-tables", false,
+		"whether to store the rune mappings and glyph metrics as encoded binary data instead of Go literals;\nuse it to keep the generated Go code small for the big fonts")
+	flag.BoolVar(&config.InlineData, "inline-data", false,
+		"whether to put the bitmap data into Go string constants instead of go:embed files")
+	flag.IntVar(&config.PageSize, "page-size", 0,
+		"a number of glyphs per independently compressed bitmap page;\n0 disables the paging, use it for the very large fonts (like CJK)")
+	flag.IntVar(&config.PageCacheSize, "page-cache", 0,
+		"a max number of decompressed pages per font segment with --page-size;\n0 means there is no limit")
 	flag.StringVar(&onMissing, "on-missing", "emptymask",
-		"a missing glyph resolution strategy (`emptymask`, `stub`, or `panic`)")
+		"a missing glyph resolution strategy (`emptymask`, `stub`, `panic`, or `fallback`)")
+	flag.StringVar(&subsetRunesString, "runes", "",
+		"a comma-separated list of rune ranges (like `0x20-0x7e,0x400-0x4ff`) to include into a result bundle;\nan empty value includes everything")
+	flag.StringVar(&subsetFromString, "subset-from", "",
+		"a comma-separated list of text file glob patterns (like `./locales/*.json`);\nonly the runes used in these files are included into a result bundle")
+	flag.BoolVar(&config.SynthesizeWhitespace, "synth-whitespace", false,
+		"whether to synthesize space, no-break space and tab glyphs if they're not defined")
+	flag.IntVar(&config.SpaceWidth, "space-width", 0,
+		"a synthesized space advance for size=1 font; 0 means the glyph width")
+	flag.IntVar(&config.TabSize, "tab-size", 4,
+		"a synthesized tab width measured in spaces")
+	flag.BoolVar(&config.ZeroWidthIgnorables, "zero-width-ignorables", false,
+		"whether to treat control and default-ignorable runes as invisible zero-width glyphs")
+	flag.StringVar(&composeString, "compose", "",
+		"a comma-separated list of rune ranges (like `0xc0-0x17f,0x400-0x4ff`)\nfor which the missing precomposed runes are synthesized")
+	flag.IntVar(&config.ComposeMarkOffset, "compose-mark-offset", 0,
+		"an above mark vertical offset for tall bases in composed glyphs;\n0 means it's computed automatically")
+	flag.BoolVar(&config.SynthesizeBoxDrawing, "synth-box-drawing", false,
+		"whether to synthesize box-drawing and block element glyphs if they're not defined")
+	flag.BoolVar(&config.SynthesizeBraille, "synth-braille", false,
+		"whether to synthesize braille pattern glyphs if they're not defined")
+	flag.IntVar(&config.BoxLineWidth, "box-line-width", 0,
+		"a synthesized light box-drawing line width for size=1 font;\n0 means it's computed from the glyph size")
+	flag.IntVar(&config.BoxHeavyLineWidth, "box-heavy-line-width", 0,
+		"a synthesized heavy box-drawing line width for size=1 font;\n0 means twice the light line width")
+	flag.BoolVar(&config.SynthesizeSmallFigures, "synth-small-figures", false,
+		"whether to synthesize superscript, subscript and vulgar fraction glyphs if they're not defined")
+	flag.StringVar(&config.SmallFiguresTag, "small-figures-tag", "",
+		"a tag that contains hand-drawn small figures for --synth-small-figures;\nif empty, the normal glyphs are downscaled")
 	flag.BoolVar(&debug, "v", false,
 		"whether to enable verbose output")
 	flag.BoolVar(&generateDocs, "generate-info", false,
@@ -41,14 +99,63 @@ func main() {
 		config.MissingGlyphAction = bitfontier.StubOnMissingGlyph
 	case "panic":
 		config.MissingGlyphAction = bitfontier.PanicOnMissingGlyph
+	case "fallback":
+		config.MissingGlyphAction = bitfontier.FallbackOnMissingGlyph
 	default:
 		panic(fmt.Sprintf("unsupported on-missing: %q", onMissing))
 	}
 
-	for _, t := range strings.Split(tagString, ",") {
-		t = strings.TrimSpace(t)
-		if t != "" {
-			config.Tags = append(config.Tags, t)
+	switch codec {
+	case "gzip", "":
+		config.Codec = bitfontier.GzipCodec
+	case "deflate":
+		config.Codec = bitfontier.DeflateCodec
+	case "rle":
+		config.Codec = bitfontier.RLECodec
+	case "none":
+		config.Codec = bitfontier.NoCodec
+	default:
+		panic(fmt.Sprintf("unsupported codec: %q", codec))
+	}
+
+	switch mapping {
+	case "auto", "":
+		config.Mapping = bitfontier.AutoMapping
+	case "ranges":
+		config.Mapping = bitfontier.RangeMapping
+	case "lut":
+		config.Mapping = bitfontier.LUTMapping
+	case "pages":
+		config.Mapping = bitfontier.PageTableMapping
+	default:
+		panic(fmt.Sprintf("unsupported mapping: %q", mapping))
+	}
+
+	config.Tags = parseList(tagString)
+	config.TagPriority = parseList(tagPriorityString)
+
+	composeRanges, err := parseRuneRanges(composeString)
+	if err != nil {
+		panic(fmt.Sprintf("parse compose ranges: %v", err))
+	}
+	config.ComposeRanges = composeRanges
+
+	subsetRunes, err := parseRuneRanges(subsetRunesString)
+	if err != nil {
+		panic(fmt.Sprintf("parse runes: %v", err))
+	}
+	config.SubsetRunes = subsetRunes
+	config.SubsetFrom = parseList(subsetFromString)
+
+	if config.SharedRuntime {
+		// The binary tables are enabled unless they're disabled explicitly,
+		// so the generator can report the incompatible options.
+		binaryTablesSet := false
+		flag.Visit(func(f *flag.Flag) {
+			binaryTablesSet = binaryTablesSet || f.Name == "binary-tables"
+		})
+		if !binaryTablesSet {
+			config.BinaryTables = true
 		}
 	}
 
@@ -65,39 +172,162 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("error: %v", err))
 	}
+	if debug {
+		printBitmapSizes(config, genResult.Bitmaps)
+	}
+	if len(genResult.MissingRunes) != 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d runes are used in the subset corpus, but not defined in the font:\n", len(genResult.MissingRunes))
+		for _, r := range genResult.MissingRunes {
+			fmt.Fprintf(os.Stderr, "\t%s (%d)\n", r.StringValue, r.Value)
+		}
+	}
 
 	if generateDocs {
 		makeDoc(config, genResult)
 	}
 }
 
+// printBitmapSizes reports the total bitmap data size
+// and the cropping savings for every codec.
+func printBitmapSizes(config bitfontier.Config, bitmaps []bitfontier.BitmapInfo) {
+	rawSize := 0
+	encodedSizes := make(map[bitfontier.BitmapCodec]int)
+	codecCropSavings := make(map[bitfontier.BitmapCodec]int)
+	for _, b := range bitmaps {
+		rawSize += b.RawSize
+		for codec, size := range b.EncodedSizes {
+			encodedSizes[codec] += size
+		}
+		for codec, savings := range b.CropSavings {
+			codecCropSavings[codec] += savings
+		}
+	}
+	fmt.Fprintf(os.Stderr, "info: bitmap data size is %d bytes\n", rawSize)
+	var sizes []float64
+	cropSavings := make(map[float64]int)
+	for _, b := range bitmaps {
+		if _, ok := cropSavings[b.Size]; !ok {
+			sizes = append(sizes, b.Size)
+		}
+		cropSavings[b.Size] += b.CropSavings[config.Codec]
+	}
+	status := " (disabled)"
+	if config.CropGlyphs {
+		status = ""
+	}
+	for _, size := range sizes {
+		fmt.Fprintf(os.Stderr, "info: size=%.2f: cropping saves %d bytes with %s%s\n", size, cropSavings[size], config.Codec, status)
+	}
+	codecs := []bitfontier.BitmapCodec{
+		bitfontier.NoCodec,
+		bitfontier.RLECodec,
+		bitfontier.DeflateCodec,
+		bitfontier.GzipCodec,
+	}
+	for _, codec := range codecs {
+		selected := ""
+		if codec == config.Codec {
+			selected = " (selected)"
+		}
+		fmt.Fprintf(os.Stderr, "info: %s codec: %d bytes, cropping saves %d bytes%s\n", codec, encodedSizes[codec], codecCropSavings[codec], selected)
+	}
+}
+
+// parseRuneRanges parses a comma-separated list of rune ranges.
+// A range is either a single rune code or a pair of codes separated by "-".
+// Codes can be specified in decimal or in hex (with "0x" prefix).
+func parseRuneRanges(s string) ([]bitfontier.RuneRange, error) {
+	var result []bitfontier.RuneRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		minString, maxString, isRange := strings.Cut(part, "-")
+		if !isRange {
+			maxString = minString
+		}
+		minValue, err := strconv.ParseInt(strings.TrimSpace(minString), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		maxValue, err := strconv.ParseInt(strings.TrimSpace(maxString), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		result = append(result, bitfontier.RuneRange{
+			Min: rune(minValue),
+			Max: rune(maxValue),
+		})
+	}
+	return result, nil
+}
+
+func parseList(s string) []string {
+	var result []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
 func makeDoc(config bitfontier.Config, genResult bitfontier.GenerationResult) {
 	var sizes []string
 	for _, s := range genResult.FontInfo.Sizes {
 		sizes = append(sizes, fmt.Sprintf("`%v`", s))
 	}
 	for i, r := range genResult.FontInfo.Runes {
-		switch r.Value {
-		case '#', '\\', '|', '!', '.', '-', '+', '*', '(', ')', '{', '}', '_':
-			// Escape markdown special symbols.
-			genResult.FontInfo.Runes[i].StringValue = "\\" + r.StringValue
-		}
+		genResult.FontInfo.Runes[i].StringValue = escapeMarkdown(r.Value, r.StringValue)
+	}
+	for i, a := range genResult.FontInfo.Aliases {
+		genResult.FontInfo.Aliases[i].StringValue = escapeMarkdown(a.Value, a.StringValue)
+		genResult.FontInfo.Aliases[i].TargetStringValue = escapeMarkdown(a.Target, a.TargetStringValue)
 	}
 	d := genResult.FontInfo.Date
 	dateString := fmt.Sprintf("%d of %s %d", d.Day(), d.Month(), d.Year())
 
+	// There can be thousands of fallbacks, so only the
+	// number of runes per target glyph is listed.
+	type fallbackTarget struct {
+		Value       rune
+		StringValue string
+		NumRunes    int
+	}
+	var fallbackTargets []fallbackTarget
+	fallbackTargetIndex := make(map[rune]int)
+	for _, fb := range genResult.FontInfo.Fallbacks {
+		i, ok := fallbackTargetIndex[fb.Target]
+		if !ok {
+			i = len(fallbackTargets)
+			fallbackTargetIndex[fb.Target] = i
+			fallbackTargets = append(fallbackTargets, fallbackTarget{
+				Value:       fb.Target,
+				StringValue: escapeMarkdown(fb.Target, fb.TargetStringValue),
+			})
+		}
+		fallbackTargets[i].NumRunes++
+	}
+	slices.SortFunc(fallbackTargets, func(a, b fallbackTarget) int {
+		return int(a.Value - b.Value)
+	})
+
 	data := struct {
 		FontName              string
 		Result                bitfontier.GenerationResult
 		SizesString           string
 		DateString            string
 		MissingRuneResolution string
+		FallbackTargets       []fallbackTarget
 	}{
 		FontName:              config.ResultPackage,
 		Result:                genResult,
 		SizesString:           strings.Join(sizes, ", "),
 		DateString:            dateString,
 		MissingRuneResolution: config.MissingGlyphAction.String(),
+		FallbackTargets:       fallbackTargets,
 	}
 
 	var buf bytes.Buffer
@@ -110,11 +340,24 @@ func makeDoc(config bitfontier.Config, genResult bitfontier.GenerationResult) {
 	}
 }
 
+func escapeMarkdown(r rune, s string) string {
+	switch r {
+	case '#', '\\', '|', '!', '.', '-', '+', '*', '(', ')', '{', '}', '_':
+		// Escape markdown special symbols.
+		return "\\" + s
+	}
+	return s
+}
+
 var docTemplate = template.Must(template.New("fontinfo").Parse(`# {{.FontName}} Bitmap Font
 
 ## Overview
 
 * Runes: {{len $.Result.FontInfo.Runes}}
+* Aliases: {{len $.Result.FontInfo.Aliases}}
+{{- if $.Result.FontInfo.Fallbacks }}
+* Fallbacks: {{len $.Result.FontInfo.Fallbacks}}
+{{- end }}
 * Sizes: {{$.SizesString}}
 * Generation date: {{$.DateString}}
 * Missing rune resolution: {{$.MissingRuneResolution}}
@@ -126,4 +369,28 @@ var docTemplate = template.Must(template.New("fontinfo").Parse(`# {{.FontName}}
 {{- range $.Result.FontInfo.Runes }}
 | {{.StringValue}} | {{.Value}} | {{.Tag}} |
 {{- end }}
+{{- if $.Result.FontInfo.Aliases }}
+
+## Aliases
+
+These runes re-use the glyphs of other runes.
+
+| Rune | Code | Rendered as | Target code |
+|---|---|---|---|
+{{- range $.Result.FontInfo.Aliases }}
+| {{.StringValue}} | {{.Value}} | {{.TargetStringValue}} | {{.Target}} |
+{{- end }}
+{{- end }}
+{{- if $.FallbackTargets }}
+
+## Fallbacks
+
+{{len $.Result.FontInfo.Fallbacks}} missing runes are rendered using their closest glyphs.
+
+| Rendered as | Target code | Fallback runes |
+|---|---|---|
+{{- range $.FallbackTargets }}
+| {{.StringValue}} | {{.Value}} | {{.NumRunes}} |
+{{- end }}
+{{- end }}
 `))
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/quasilyte/bitfontier"
+	"github.com/quasilyte/bitfontier/internal/fontgen"
+)
+
+func TestMakeDocAliases(t *testing.T) {
+	config := bitfontier.Config{
+		ResultPackage: "testfont",
+		OutDir:        t.TempDir(),
+	}
+	result := bitfontier.GenerationResult{
+		FontInfo: fontgen.FontInfo{
+			Runes: []fontgen.RuneInfo{
+				{Value: 'o', StringValue: "o", Tag: "latin"},
+				{Value: '*', StringValue: "*", Tag: "latin"},
+			},
+			Aliases: []fontgen.AliasInfo{
+				{Value: '0', StringValue: "0", Target: 'o', TargetStringValue: "o"},
+				{Value: '|', StringValue: "|", Target: '*', TargetStringValue: "*"},
+				{Value: '\u00a0', StringValue: "U+00A0", Target: '_', TargetStringValue: "_"},
+			},
+		},
+	}
+	makeDoc(config, result)
+
+	data, err := os.ReadFile(filepath.Join(config.OutDir, "fontinfo.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(data)
+	lines := []string{
+		"* Runes: 2",
+		"* Aliases: 3",
+		`| o | 111 | latin |`,
+		`| \* | 42 | latin |`,
+		"## Aliases",
+		`| 0 | 48 | o | 111 |`,
+		`| \| | 124 | \* | 42 |`,
+		`| U+00A0 | 160 | \_ | 95 |`,
+	}
+	for _, l := range lines {
+		if !strings.Contains(doc, l+"\n") {
+			t.Fatalf("fontinfo.md doesn't contain %q:\n%s", l, doc)
+		}
+	}
+}
+
+func TestMakeDocNoAliases(t *testing.T) {
+	config := bitfontier.Config{
+		ResultPackage: "testfont",
+		OutDir:        t.TempDir(),
+	}
+	result := bitfontier.GenerationResult{
+		FontInfo: fontgen.FontInfo{
+			Runes: []fontgen.RuneInfo{
+				{Value: 'o', StringValue: "o", Tag: "latin"},
+			},
+		},
+	}
+	makeDoc(config, result)
+
+	data, err := os.ReadFile(filepath.Join(config.OutDir, "fontinfo.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := string(data); strings.Contains(doc, "## Aliases") {
+		t.Fatalf("unexpected aliases section:\n%s", doc)
+	}
+}
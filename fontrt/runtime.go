@@ -0,0 +1,213 @@
+// Package fontrt is the shared runtime of the generated bitmap font packages.
+//
+// By default, every generated package gets its own copy of the runtime.
+// The packages generated with the shared runtime option (see the --shared-runtime flag)
+// contain only the font data and the constructors that use this package instead.
+// This way, several fonts don't duplicate the runtime code
+// and the functions like [Scale] work with the fonts of any such package.
+//
+// The exported API that is not re-exported by the generated packages
+// is only meant to be used by the generated code.
+package fontrt
+
+import (
+	"fmt"
+
+	"golang.org/x/image/font"
+)
+
+//go:generate go run ../internal/cmd/genfontrt
+
+// Version is the generated code version implemented by this runtime.
+// It's incremented on every incompatible change of the generated code
+// or the data formats.
+const Version = 1
+
+// MinVersion is the oldest generated code version supported by this runtime.
+const MinVersion = 1
+
+// EnforceVersion is used by the generated packages to check their
+// compatibility with the runtime during the compilation:
+//
+//	const (
+//		_ = fontrt.EnforceVersion(V - fontrt.MinVersion)
+//		_ = fontrt.EnforceVersion(fontrt.Version - V)
+//	)
+//
+// Where V is the generated code version. If V is out of the supported range,
+// one of these constants overflows and the package doesn't compile.
+// The fix is to use the same bitfontier version for the generation and the runtime.
+type EnforceVersion uint
+
+// The rest of this file replaces the specializations that the generator
+// injects into the non-shared packages (see stubs.go in the runtime sources).
+// The shared runtime supports every data format the generator can select,
+// so the format details are stored inside the data.
+
+// missingStrategy is the font missing glyph action, every font sets its own.
+type missingStrategy string
+
+func (s missingStrategy) get() string { return string(s) }
+
+type dataIndex = uint32
+
+type metricsValue = uint16
+
+type glyphMetrics struct {
+	minX, minY, maxX, maxY metricsValue
+	advance                metricsValue
+}
+
+// The codec IDs, they should be identical to the generator BitmapCodec values.
+const (
+	codecGzip = iota
+	codecNone
+	codecDeflate
+	codecRLE
+)
+
+// croppedFlag is set in the glyph bitmaps data first byte
+// if the glyphs are cropped (see the generator CropGlyphs option).
+const croppedFlag = 0x80
+
+// isCropped reports whether the glyph bitmaps data has the croppedFlag.
+func isCropped(data string) bool {
+	return data != "" && data[0]&croppedFlag != 0
+}
+
+// uncompress decodes the data using the codec selected by its first byte.
+func uncompress(data string) []byte {
+	if data == "" {
+		panic("uncompress: empty data")
+	}
+	codec, data := data[0]&^croppedFlag, data[1:]
+	switch codec {
+	case codecGzip:
+		return gunzip(data)
+	case codecNone:
+		return []byte(data)
+	case codecDeflate:
+		return inflate(data)
+	case codecRLE:
+		return decodeRLE(data)
+	default:
+		panic(fmt.Sprintf("uncompress: unexpected codec %d", codec))
+	}
+}
+
+// The mapping strategy IDs, they should be identical to the generator MappingStrategy values.
+const (
+	mappingRanges = iota + 1
+	mappingLUT
+	mappingPages
+)
+
+// runeMapping dispatches the lookups to the strategy selected by the generator.
+// The strategy ID precedes the mapping in the segment tables.
+type runeMapping struct {
+	kind   uint
+	ranges rangeMapping
+	lut    lutMapping
+	pages  pageMapping
+}
+
+func (m *runeMapping) lookup(r rune) (uint, bool) {
+	switch m.kind {
+	case mappingRanges:
+		return m.ranges.lookup(r)
+	case mappingLUT:
+		return m.lut.lookup(r)
+	case mappingPages:
+		return m.pages.lookup(r)
+	default:
+		return 0, false
+	}
+}
+
+func (m *runeMapping) decode(r *tableReader) {
+	m.kind = r.uint()
+	switch m.kind {
+	case mappingRanges:
+		m.ranges.decode(r)
+	case mappingLUT:
+		m.lut.decode(r)
+	case mappingPages:
+		m.pages.decode(r)
+	default:
+		panic(fmt.Sprintf("decode tables: unexpected mapping %d", m.kind))
+	}
+}
+
+// Segment is a font segment created by the generated code.
+// All fonts of the same size share their segments.
+type Segment struct {
+	seg fontSegment
+}
+
+// NewSegment creates a segment with the encoded glyph bitmaps and tables.
+func NewSegment(data, tables string, glyphWidth, glyphHeight, numGlyphs int, minRune, maxRune rune) *Segment {
+	return &Segment{
+		seg: fontSegment{
+			img:     newCompressedBitmapImage(data, glyphWidth, glyphHeight, numGlyphs),
+			tables:  tables,
+			MinRune: minRune,
+			MaxRune: maxRune,
+		},
+	}
+}
+
+// NewPagedSegment is like NewSegment, but the glyph bitmaps are stored in pages.
+func NewPagedSegment(data, tables string, pageOffsets []uint32, glyphWidth, glyphHeight, numGlyphs, pageSize, pageCacheSize int, minRune, maxRune rune) *Segment {
+	return &Segment{
+		seg: fontSegment{
+			pages:   newBitmapPages(data, pageOffsets, glyphWidth, glyphHeight, numGlyphs, pageSize, pageCacheSize),
+			tables:  tables,
+			MinRune: minRune,
+			MaxRune: maxRune,
+		},
+	}
+}
+
+// TagSegment is a tag segment that is added to the font only if its tag is included.
+type TagSegment struct {
+	Tag     string
+	Segment *Segment
+}
+
+// FontConfig describes a font of the specific size.
+type FontConfig struct {
+	Core *Segment
+
+	DotX      int
+	DotY      int
+	XHeight   int
+	CapHeight int
+
+	OnMissing string
+
+	// Tags are the font tags that can be selected using WithTags,
+	// it's nil if the font is generated without the tag segments.
+	Tags []string
+
+	// StubIndex and ZeroWidthIndex are the core segment data indices.
+	// A negative ZeroWidthIndex disables the zero-width ignorables.
+	StubIndex      int
+	ZeroWidthIndex int
+}
+
+// NewFont allocates a font that includes the core segment
+// and the tag segments that are selected by the options.
+func NewFont(config FontConfig, segments []TagSegment, opts []Option) font.Face {
+	f := newBitmapFont(0, &config.Core.seg, config.DotX, config.DotY)
+	f.onMissing = missingStrategy(config.OnMissing)
+	f.XHeight = config.XHeight
+	f.CapHeight = config.CapHeight
+	f.StubIndex = uint(config.StubIndex)
+	f.ZeroWidthIndex = config.ZeroWidthIndex
+	tagSegments := make([]tagSegment, len(segments))
+	for i, s := range segments {
+		tagSegments[i] = tagSegment{tag: s.Tag, seg: &s.Segment.seg}
+	}
+	f.load(config.Tags, tagSegments, opts)
+	return f
+}
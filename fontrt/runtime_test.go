@@ -0,0 +1,137 @@
+package fontrt
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// newTestSegment creates a segment with two 2x2 glyphs for 'a' and 'b'
+// that is encoded like the generator does it for the shared runtime.
+func newTestSegment(mapping uint, cropped bool) *Segment {
+	// 'a' is a top-left pixel, 'b' is a bottom row.
+	data := []byte{
+		codecNone,
+		0b11000001,
+	}
+	if cropped {
+		data = []byte{
+			codecNone | croppedFlag,
+			0, 0, 1, 1,
+			0, 1, 2, 1,
+			0b111,
+		}
+	}
+
+	tables := []byte{codecNone}
+	putUint := func(values ...uint) {
+		for _, v := range values {
+			tables = binary.AppendUvarint(tables, uint64(v))
+		}
+	}
+	putUint(mapping)
+	switch mapping {
+	case mappingRanges:
+		putUint(1, 'a', 2, 0)
+	case mappingLUT:
+		tables = binary.AppendVarint(tables, 'a')
+		putUint(2, 1, 2)
+	case mappingPages:
+		tables = binary.AppendVarint(tables, 'a')
+		entries := make([]uint, 512) // The shared empty page and the 'a' page
+		entries[256], entries[257] = 1, 2
+		putUint(1, 1)
+		putUint(uint(len(entries)))
+		putUint(entries...)
+	}
+	putUint(2, 0, 0, 1, 1, 2, 0, 1, 2, 2, 2) // The metrics
+	putUint(0)                               // No anchors
+
+	return NewSegment(string(data), string(tables), 2, 2, 2, 'a', 'b')
+}
+
+func TestSharedRuntime(t *testing.T) {
+	type pixel struct {
+		r    rune
+		x, y int
+	}
+	opaque := map[pixel]bool{
+		{'a', 0, 0}: true,
+		{'b', 0, 1}: true,
+		{'b', 1, 1}: true,
+	}
+
+	for _, cropped := range []bool{false, true} {
+		for _, mapping := range []uint{mappingRanges, mappingLUT, mappingPages} {
+			core := newTestSegment(mapping, cropped)
+			f := NewFont(FontConfig{Core: core, DotY: 2, OnMissing: "emptymask", ZeroWidthIndex: -1}, nil, nil)
+
+			if _, ok := f.GlyphAdvance('c'); ok {
+				t.Fatalf("mapping%d: 'c' is not expected to be found", mapping)
+			}
+			for _, r := range "ab" {
+				_, mask, maskp, advance, ok := f.Glyph(fixed.P(0, 2), r)
+				if !ok || advance != fixed.I(2) {
+					t.Fatalf("mapping%d: Glyph(%q): have advance=%v (ok=%v), want 2", mapping, r, advance, ok)
+				}
+				for y := 0; y < 2; y++ {
+					for x := 0; x < 2; x++ {
+						_, _, _, a := mask.At(maskp.X+x, maskp.Y+y).RGBA()
+						if have, want := a != 0, opaque[pixel{r, x, y}]; have != want {
+							t.Fatalf("mapping%d cropped=%v: %q pixel (%d, %d): have %v, want %v", mapping, cropped, r, x, y, have, want)
+						}
+					}
+				}
+			}
+
+			// The faces of all shared runtime packages can be scaled.
+			scaled := Scale(f, 2)
+			if advance, ok := scaled.GlyphAdvance('b'); !ok || advance != fixed.I(4) {
+				t.Fatalf("mapping%d: scaled advance: have %v (ok=%v), want 4", mapping, advance, ok)
+			}
+		}
+	}
+}
+
+func TestMissingGlyph(t *testing.T) {
+	// Unlike the generated packages, the shared runtime
+	// selects the missing glyph strategy per font.
+	for _, onMissing := range []string{"emptymask", "stub", "panic"} {
+		config := FontConfig{
+			Core:           newTestSegment(mappingRanges, true),
+			DotY:           2,
+			OnMissing:      onMissing,
+			StubIndex:      1,
+			ZeroWidthIndex: -1,
+		}
+		f := NewFont(config, nil, nil)
+
+		if onMissing == "panic" {
+			panicked := func() (panicked bool) {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				f.GlyphBounds('c')
+				return false
+			}()
+			if !panicked {
+				t.Fatalf("%s: GlyphBounds('c') didn't panic", onMissing)
+			}
+			continue
+		}
+
+		_, _, _, glyphAdvance, glyphOK := f.Glyph(fixed.P(0, 2), 'c')
+		advance, advanceOK := f.GlyphAdvance('c')
+		_, boundsAdvance, boundsOK := f.GlyphBounds('c')
+		wantOK := onMissing == "stub"
+		if glyphOK != wantOK || advanceOK != wantOK || boundsOK != wantOK {
+			t.Fatalf("%s: Glyph ok=%v, GlyphAdvance ok=%v, GlyphBounds ok=%v, want %v",
+				onMissing, glyphOK, advanceOK, boundsOK, wantOK)
+		}
+		if wantOK && (glyphAdvance != fixed.I(2) || advance != fixed.I(2) || boundsAdvance != fixed.I(2)) {
+			t.Fatalf("%s: advances: Glyph=%v GlyphAdvance=%v GlyphBounds=%v, want 2",
+				onMissing, glyphAdvance, advance, boundsAdvance)
+		}
+	}
+}
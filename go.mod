@@ -1,3 +1,8 @@
 module github.com/quasilyte/bitfontier
 
 go 1.21
+
+require (
+	golang.org/x/image v0.18.0
+	golang.org/x/text v0.20.0
+)
@@ -0,0 +1,52 @@
+// genfontrt copies the font runtime sources into the fontrt package.
+//
+// The generated font packages get their own copies of these sources,
+// specialized by the generator (see internal/fontgen/_libfiles/fontimpl).
+// The fontrt package is the shared version of the same runtime
+// that is used with the Config.SharedRuntime option.
+//
+// It's run by go generate from the fontrt package directory.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	if err := copyRuntime("../internal/fontgen/_libfiles/fontimpl", "."); err != nil {
+		fmt.Fprintf(os.Stderr, "genfontrt: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func copyRuntime(srcDir, dstDir string) error {
+	files, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		// The stubs are replaced by the shared runtime.go
+		// and the tests depend on the specialized versions.
+		if f.Name() == "stubs.go" || strings.HasSuffix(f.Name(), "_test.go") {
+			continue
+		}
+		code, err := os.ReadFile(filepath.Join(srcDir, f.Name()))
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(code, []byte("package fontimpl")) {
+			return fmt.Errorf("%s: unexpected package clause", f.Name())
+		}
+		code = bytes.TrimPrefix(code, []byte("package fontimpl"))
+		header := "// Code generated by genfontrt from internal/fontgen/_libfiles/fontimpl. DO NOT EDIT.\n\npackage fontrt"
+		code = append([]byte(header), code...)
+		if err := os.WriteFile(filepath.Join(dstDir, f.Name()), code, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRuntimeInSync checks that fontrt is regenerated after the runtime sources changes.
+func TestRuntimeInSync(t *testing.T) {
+	const (
+		srcDir    = "../../fontgen/_libfiles/fontimpl"
+		fontrtDir = "../../../fontrt"
+	)
+	dstDir := t.TempDir()
+	if err := copyRuntime(srcDir, dstDir); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		want, err := os.ReadFile(filepath.Join(dstDir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		have, err := os.ReadFile(filepath.Join(fontrtDir, f.Name()))
+		if err != nil {
+			t.Fatalf("%v (run go generate in fontrt)", err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Fatalf("fontrt/%s is out of date (run go generate in fontrt)", f.Name())
+		}
+	}
+
+	// The files removed from the runtime sources should be removed from fontrt too.
+	fontrtFiles, err := os.ReadDir(fontrtDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fontrtFiles {
+		code, err := os.ReadFile(filepath.Join(fontrtDir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(code, []byte("// Code generated by genfontrt")) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dstDir, f.Name())); err != nil {
+			t.Fatalf("fontrt/%s is not generated from the runtime sources", f.Name())
+		}
+	}
+}
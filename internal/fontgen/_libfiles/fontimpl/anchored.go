@@ -0,0 +1,108 @@
+package fontimpl
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// WithMarkAnchors returns a font that attaches the combining marks
+// to the anchors of their base glyphs and stacks them, just like [DrawString] does.
+// This makes the [font.Drawer] and [font.BoundString] results
+// identical to the [DrawString] and [BoundString] ones.
+//
+// A plain font places the marks over the default base, since its Glyph method
+// doesn't know the previous rune. The returned font gets the base rune from
+// the Kern call that precedes every Glyph (or GlyphBounds) call of the mark.
+// This state makes it unsafe for concurrent use: create a separate
+// font for every goroutine, it's cheap and it shares all the glyph data.
+//
+// The other functions of this package accept the result too.
+//
+// This function will only work with fonts created by
+// this package. Any other font will make it panic.
+func WithMarkAnchors(f font.Face) font.Face {
+	switch f := f.(type) {
+	case *bitmapFont:
+		return &anchoredFont{face: f, font: f, scale: 1}
+	case *scaledFont:
+		return &anchoredFont{face: f, font: f.font, scale: f.scale}
+	case *anchoredFont:
+		return WithMarkAnchors(f.face)
+	default:
+		panic(fmt.Sprintf("expected a bitmap font, got %T", f))
+	}
+}
+
+// anchoredFont is a font view that places the marks using
+// the base glyph anchors, see [WithMarkAnchors].
+type anchoredFont struct {
+	face  font.Face // Either *bitmapFont or *scaledFont
+	font  *bitmapFont
+	scale int
+
+	placement markPlacement
+
+	// mark is the rune of the pending offset set by Kern,
+	// it's applied by the next Glyph or GlyphBounds call for this rune.
+	mark   rune
+	offset image.Point
+}
+
+// unwrapAnchored returns the font that is wrapped by WithMarkAnchors.
+func unwrapAnchored(f font.Face) font.Face {
+	if a, ok := f.(*anchoredFont); ok {
+		return a.face
+	}
+	return f
+}
+
+func (a *anchoredFont) Close() error {
+	return a.face.Close()
+}
+
+func (a *anchoredFont) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	dr, mask, maskp, advance, ok = a.face.Glyph(dot, r)
+	return dr.Add(a.takeOffset(r)), mask, maskp, advance, ok
+}
+
+func (a *anchoredFont) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	return a.face.GlyphAdvance(r)
+}
+
+func (a *anchoredFont) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	bounds, advance, ok = a.face.GlyphBounds(r)
+	offset := a.takeOffset(r)
+	delta := fixed.P(offset.X, offset.Y)
+	return fixed.Rectangle26_6{Min: bounds.Min.Add(delta), Max: bounds.Max.Add(delta)}, advance, ok
+}
+
+func (a *anchoredFont) Kern(r0, r1 rune) fixed.Int26_6 {
+	a.mark = 0
+	if a.font.hasAnchors && isMark(r1) {
+		if g, ok := a.font.lookup(r1); ok {
+			// Glyph places the mark over the default base,
+			// move it to the actual base glyph anchors.
+			offset := a.font.placeMark(&a.placement, r0, r1, g).Sub(a.font.defaultMarkOffset(r1))
+			a.mark = r1
+			a.offset = offset.Mul(a.scale)
+		}
+	}
+	return a.face.Kern(r0, r1)
+}
+
+func (a *anchoredFont) Metrics() font.Metrics {
+	return a.face.Metrics()
+}
+
+// takeOffset returns the r mark offset set by the last Kern call.
+// The offset is applied only once.
+func (a *anchoredFont) takeOffset(r rune) image.Point {
+	if a.mark != r || a.mark == 0 {
+		return image.Point{}
+	}
+	a.mark = 0
+	return a.offset
+}
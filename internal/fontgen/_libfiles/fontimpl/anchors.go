@@ -0,0 +1,150 @@
+package fontimpl
+
+import (
+	"image"
+)
+
+const (
+	anchorTop uint8 = 1 << iota
+	anchorBottom
+	anchorMarkTop
+	anchorMarkBottom
+)
+
+// glyphAnchors describe how combining marks are positioned over the base glyphs.
+// All points are specified in the glyph cell coordinates.
+//
+// top and bottom are the points where above and below marks are attached.
+// A mark can have them too, this is how the marks are stacked.
+// mark is a mark glyph point that is aligned with one of the base anchors,
+// anchorMarkTop and anchorMarkBottom flags select which one.
+type glyphAnchors struct {
+	r      rune
+	flags  uint8
+	top    [2]int8
+	bottom [2]int8
+	mark   [2]int8
+}
+
+// Marks that have no anchors are drawn at their default position:
+// the mark glyph cell covers the previous glyph cell.
+//
+// The anchored marks placement depends on the base glyph, but a face
+// Glyph call only knows the mark rune. So the Face methods place such marks
+// over the default base: above marks are attached right above the lowercase
+// letters (the x-height), below marks are attached right below the baseline.
+// [DrawString] knows the previous runes, so it attaches the marks
+// to the anchors of their base glyphs and stacks them.
+
+// defaultMarkOffset returns the r mark position delta relative
+// to its default position when it's placed over the default base.
+// The mark is not moved horizontally.
+func (f *bitmapFont) defaultMarkOffset(r rune) image.Point {
+	a := f.findAnchors(r)
+	if a == nil || a.flags&(anchorMarkTop|anchorMarkBottom) == 0 {
+		return image.Point{}
+	}
+	return image.Pt(0, f.defaultAttachY(a)-int(a.mark[1]))
+}
+
+// defaultAttachY returns the default base attachment point y
+// for the mark with the given anchors.
+func (f *bitmapFont) defaultAttachY(mark *glyphAnchors) int {
+	dotY := f.DotY.Floor()
+	switch {
+	case mark.flags&anchorMarkBottom != 0:
+		return dotY + 1
+	case f.XHeight == 0:
+		// The x-height is unknown, keep the mark at its own position.
+		return int(mark.mark[1])
+	default:
+		return dotY - f.XHeight
+	}
+}
+
+// markPlacement is the [DrawString] state of the marks
+// that are attached to the same base glyph.
+type markPlacement struct {
+	// mark is the last placed mark rune.
+	// A zero value means there is no active placement.
+	mark rune
+
+	// flags tell which of the attachment points below are set.
+	// The unset ones are replaced by the default base points.
+	flags uint8
+
+	// top and bottom are the attachment points for the next (stacked) mark.
+	// They're specified in the base glyph cell coordinates.
+	top    image.Point
+	bottom image.Point
+
+	baseAdvance int
+}
+
+// placeMark returns the r1 mark position delta relative to its
+// default position when it follows r0 and updates the placement.
+// r0 could be either a base glyph or another mark placed with p.
+func (f *bitmapFont) placeMark(p *markPlacement, r0, r1 rune, mark glyphRef) image.Point {
+	markAnchors := f.findAnchors(r1)
+	if markAnchors == nil || markAnchors.flags&(anchorMarkTop|anchorMarkBottom) == 0 {
+		p.mark = 0
+		return image.Point{}
+	}
+	attachFlag := anchorTop
+	if markAnchors.flags&anchorMarkTop == 0 {
+		attachFlag = anchorBottom
+	}
+	markAdvance := int(mark.metrics().advance)
+
+	// The attachment points are inherited from the previous mark
+	// if they're stacked, otherwise r0 is a new base glyph.
+	if !isMark(r0) || p.mark != r0 {
+		*p = markPlacement{baseAdvance: markAdvance}
+		if base, ok := f.lookup(r0); ok {
+			p.baseAdvance = int(base.metrics().advance)
+		}
+		if baseAnchors := f.findAnchors(r0); baseAnchors != nil {
+			p.flags = baseAnchors.flags & (anchorTop | anchorBottom)
+			p.top = image.Pt(int(baseAnchors.top[0]), int(baseAnchors.top[1]))
+			p.bottom = image.Pt(int(baseAnchors.bottom[0]), int(baseAnchors.bottom[1]))
+		}
+	}
+
+	// By default, the mark cell is placed at the base advance minus the mark advance.
+	// This is what the Kern method does for marks.
+	defaultX := p.baseAdvance - markAdvance
+
+	var attach image.Point
+	switch {
+	case p.flags&attachFlag == 0:
+		attach = image.Pt(defaultX+int(markAnchors.mark[0]), f.defaultAttachY(markAnchors))
+	case attachFlag == anchorTop:
+		attach = p.top
+	default:
+		attach = p.bottom
+	}
+	// The mark cell origin in the base glyph cell coordinates.
+	origin := attach.Sub(image.Pt(int(markAnchors.mark[0]), int(markAnchors.mark[1])))
+
+	// Update the attachment point for the next mark of the same kind.
+	// If the mark doesn't have an explicit anchor for that,
+	// the next mark is attached right above (or below) this mark ink.
+	ink := mark.metrics()
+	if attachFlag == anchorTop {
+		if markAnchors.flags&anchorTop != 0 {
+			p.top = origin.Add(image.Pt(int(markAnchors.top[0]), int(markAnchors.top[1])))
+		} else {
+			p.top = image.Pt(attach.X, origin.Y+int(ink.minY)-1)
+		}
+	} else {
+		if markAnchors.flags&anchorBottom != 0 {
+			p.bottom = origin.Add(image.Pt(int(markAnchors.bottom[0]), int(markAnchors.bottom[1])))
+		} else {
+			p.bottom = image.Pt(attach.X, origin.Y+int(ink.maxY))
+		}
+	}
+	p.flags |= attachFlag
+	p.mark = r1
+
+	return origin.Sub(image.Pt(defaultX, 0))
+}
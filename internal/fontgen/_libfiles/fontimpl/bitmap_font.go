@@ -10,32 +10,53 @@ import (
 )
 
 type bitmapFont struct {
-	img         *bitmapImage
 	glyphWidth  int
 	glyphHeight int
 	id          int
 
-	lastGlyphRune  rune
-	lastGlyphIndex int
+	// onMissing is the missing glyph strategy, see [bitmapFont.lookup].
+	onMissing missingStrategy
 
-	MinRune      rune
-	MaxRune      rune
-	RuneMapping  []runeAndIndex
-	GlyphBitSize uint
-	CapHeight    int
-	XHeight      int
-	DotX         fixed.Int26_6
-	DotY         fixed.Int26_6
+	// core is also the first element of the segments slice.
+	core       *fontSegment
+	segments   []*fontSegment
+	hasAnchors bool
+	alphaMasks bool
+
+	// StubIndex and ZeroWidthIndex are the core segment data indices.
+	StubIndex uint
+
+	// ZeroWidthIndex is used for the default-ignorable runes.
+	// A negative value means that they're treated like any other rune.
+	ZeroWidthIndex int
+
+	CapHeight int
+	XHeight   int
+	DotX      fixed.Int26_6
+	DotY      fixed.Int26_6
 }
 
-func newBitmapFont(id int, img *bitmapImage, dotX, dotY int) *bitmapFont {
-	return &bitmapFont{
+func newBitmapFont(id int, core *fontSegment, dotX, dotY int) *bitmapFont {
+	w, h := core.cellSize()
+	f := &bitmapFont{
 		id:          id,
-		img:         img,
-		glyphWidth:  int(img.width),
-		glyphHeight: int(img.height),
+		glyphWidth:  w,
+		glyphHeight: h,
 		DotX:        fixed.I(dotX),
 		DotY:        fixed.I(dotY),
+		core:        core,
+
+		ZeroWidthIndex: -1,
+	}
+	f.addSegment(core)
+	return f
+}
+
+func (f *bitmapFont) addSegment(s *fontSegment) {
+	s.loadTables()
+	f.segments = append(f.segments, s)
+	if len(s.Anchors) != 0 {
+		f.hasAnchors = true
 	}
 }
 
@@ -44,30 +65,26 @@ func (f *bitmapFont) Close() error {
 }
 
 func (f *bitmapFont) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
-	// maskp remains a zero value as we don't need it.
-	dr, mask, advance, ok = f.glyph(dot, r)
-	return dr, mask, maskp, advance, ok
+	g, dr, advance, ok := f.glyph(dot, r)
+	if !ok {
+		return dr, nil, maskp, advance, false
+	}
+	img, origin := g.seg.glyphImage(g.index)
+	return dr, f.mask(img), origin, advance, true
 }
 
-func (f *bitmapFont) glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask *bitmapImage, advance fixed.Int26_6, ok bool) {
-	// First do a quick range check.
-	if r > f.MaxRune || r < f.MinRune {
-		return dr, mask, advance, false
+// mask returns the glyph atlas that is used for the Glyph masks.
+func (f *bitmapFont) mask(img *bitmapImage) image.Image {
+	if f.alphaMasks {
+		return img.alpha()
 	}
+	return img
+}
 
-	// Map rune to its index inside the associated data.
-	index, ok := f.getRuneDataIndex(r)
+func (f *bitmapFont) glyph(dot fixed.Point26_6, r rune) (g glyphRef, dr image.Rectangle, advance fixed.Int26_6, ok bool) {
+	g, ok = f.lookup(r)
 	if !ok {
-		// onMissing is a const, so the compiler should eliminate
-		// all checks here and keep the right case only.
-		switch onMissing {
-		case "emptymask":
-			return dr, mask, advance, false
-		case "stub":
-			index = getStubImageIndex(f.id)
-		case "panic":
-			panic(fmt.Sprintf("requesting an undefined rune %v (%q)", r, r))
-		}
+		return g, dr, advance, false
 	}
 
 	rw := f.glyphWidth
@@ -75,22 +92,53 @@ func (f *bitmapFont) glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mas
 	dx := (dot.X - f.DotX).Floor()
 	dy := (dot.Y - f.DotY).Floor()
 	dr = image.Rect(dx, dy, dx+rw, dy+rh)
+	if f.hasAnchors && isMark(r) {
+		dr = dr.Add(f.defaultMarkOffset(r))
+	}
 
-	offset := index * f.GlyphBitSize
-	mask = f.img.WithOffset(offset)
-	advance = fixed.I(rw)
-	return dr, mask, advance, true
+	advance = fixed.I(int(g.metrics().advance))
+	return g, dr, advance, true
 }
 
 func (f *bitmapFont) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
-	if r > f.MaxRune || r < f.MinRune {
+	g, ok := f.lookup(r)
+	if !ok {
 		return 0, false
 	}
-	return fixed.I(f.glyphWidth), true
+	return fixed.I(int(g.metrics().advance)), true
 }
 
+// GlyphBounds returns the glyph ink rectangle.
+// A glyph without any ink (like a space) has empty bounds.
+//
+// Use [CellBounds] to get a face that reports
+// the entire glyph cell rectangle instead.
 func (f *bitmapFont) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
-	if r > f.MaxRune || r < f.MinRune {
+	g, ok := f.lookup(r)
+	if !ok {
+		return bounds, advance, false
+	}
+	ink := g.metrics()
+	advance = fixed.I(int(ink.advance))
+	if ink.minX == ink.maxX {
+		return bounds, advance, true
+	}
+	bounds = fixed.Rectangle26_6{
+		Min: fixed.Point26_6{
+			X: -f.DotX + fixed.I(int(ink.minX)),
+			Y: -f.DotY + fixed.I(int(ink.minY)),
+		},
+		Max: fixed.Point26_6{
+			X: -f.DotX + fixed.I(int(ink.maxX)),
+			Y: -f.DotY + fixed.I(int(ink.maxY)),
+		},
+	}
+	return f.markBounds(r, bounds), advance, true
+}
+
+func (f *bitmapFont) cellGlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	g, ok := f.lookup(r)
+	if !ok {
 		return bounds, advance, false
 	}
 	bounds = fixed.Rectangle26_6{
@@ -100,16 +148,32 @@ func (f *bitmapFont) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fi
 			Y: -f.DotY + fixed.I(f.glyphHeight),
 		},
 	}
-	advance = fixed.I(f.glyphWidth)
-	return bounds, advance, true
+	advance = fixed.I(int(g.metrics().advance))
+	return f.markBounds(r, bounds), advance, true
+}
+
+// markBounds moves the glyph bounds the same way Glyph moves the marks.
+func (f *bitmapFont) markBounds(r rune, bounds fixed.Rectangle26_6) fixed.Rectangle26_6 {
+	if !f.hasAnchors || !isMark(r) {
+		return bounds
+	}
+	offset := f.defaultMarkOffset(r)
+	delta := fixed.P(offset.X, offset.Y)
+	return fixed.Rectangle26_6{Min: bounds.Min.Add(delta), Max: bounds.Max.Add(delta)}
 }
 
 func (f *bitmapFont) Kern(r0, r1 rune) fixed.Int26_6 {
-	if unicode.Is(unicode.Mn, r1) {
-		return -fixed.I(f.glyphWidth)
+	if !isMark(r1) {
+		return 0
 	}
-	return 0
 
+	mark, ok := f.lookup(r1)
+	if !ok {
+		return 0
+	}
+	// Compensate the mark advance, so it's drawn over the previous glyph.
+	// The anchors-based placement is applied by Glyph.
+	return -fixed.I(int(mark.metrics().advance))
 }
 
 func (f *bitmapFont) Metrics() font.Metrics {
@@ -123,51 +187,47 @@ func (f *bitmapFont) Metrics() font.Metrics {
 	}
 }
 
-func (f *bitmapFont) getRuneDataIndex(r rune) (uint, bool) {
-	slice := f.RuneMapping
-
-	// A heuristic search that depends on the previous binary search result.
-	// Since most of the time we're looking for a rune from the
-	// same language, these runes might be very close to each other.
-	// If there are no gaps in between these two runes, we
-	// can find the target rune data index with a simple calculation.
-	//
-	// If there is a gap, this strategy will fail and we'll proceed
-	// to the binary search below.
-	//
-	// When it works, it gives 20-25% Glyph() speedup
-	// and turns the lookup into O(1).
-	{
-		delta := int(r) - int(f.lastGlyphRune)
-		index := uint(f.lastGlyphIndex + delta)
-		if index < uint(len(slice)) {
-			if rune(slice[index].r) == r {
-				return uint(slice[index].i), true
-			}
+// lookup maps the rune to its glyph location.
+// If the rune is not defined, the missing glyph strategy is applied.
+//
+// All Face methods use this function to resolve the runes,
+// so the measurements are always consistent with Glyph results.
+func (f *bitmapFont) lookup(r rune) (glyphRef, bool) {
+	for i, s := range f.segments {
+		if index, ok := s.getRuneDataIndex(r); ok {
+			return glyphRef{seg: s, segIndex: i, index: index}, true
 		}
 	}
 
-	// This is an inlined sort.Search specialized for our slice.
+	if f.ZeroWidthIndex >= 0 && isIgnorable(r) {
+		return glyphRef{seg: f.core, index: uint(f.ZeroWidthIndex)}, true
+	}
 
-	i, j := 0, len(slice)
-	for i < j {
-		h := int(uint(i+j) >> 1)
-		v := slice[h]
-		// The explicit rune conversion is necessary here.
-		// v.r could be uint16 when small rune optimization is in order.
-		if rune(v.r) < r {
-			i = h + 1
-		} else {
-			j = h
-		}
+	// The generated packages use the package constant strategy,
+	// unless the font sets its own one (like the shared runtime fonts do).
+	switch f.onMissing.get() {
+	case "stub":
+		return glyphRef{seg: f.core, index: f.StubIndex}, true
+	case "panic":
+		panic(fmt.Sprintf("requesting an undefined rune %v (%q)", r, r))
+	default: // "emptymask" and "fallback"
+		return glyphRef{}, false
 	}
+}
 
-	if i < len(slice) && rune(slice[i].r) == r {
-		// Save the results for the heuristic search above.
-		f.lastGlyphRune = r
-		f.lastGlyphIndex = i
-		return uint(slice[i].i), true
+// findAnchors returns the r anchors from any of the font segments.
+// A base glyph and a mark can belong to different segments.
+func (f *bitmapFont) findAnchors(r rune) *glyphAnchors {
+	for _, s := range f.segments {
+		if a := s.findAnchors(r); a != nil {
+			return a
+		}
 	}
+	return nil
+}
 
-	return 0, false
+// isMark reports whether r is a combining mark that
+// should be drawn over the previous glyph instead of advancing.
+func isMark(r rune) bool {
+	return unicode.Is(unicode.Mn, r)
 }
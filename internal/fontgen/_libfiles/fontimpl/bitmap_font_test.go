@@ -0,0 +1,1238 @@
+package fontimpl
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// testRune is a rune mapping entry: the rune and its data index.
+type testRune struct {
+	r rune
+	i uint
+}
+
+// initTestMapping sets the segment rune range and mapping
+// using whatever mapping strategy this package has.
+// The runes should be sorted.
+func initTestMapping(s *fontSegment, runes ...testRune) {
+	s.MinRune, s.MaxRune = 0, -1
+	if len(runes) == 0 {
+		return
+	}
+	s.MinRune = runes[0].r
+	s.MaxRune = runes[len(runes)-1].r
+
+	const pageSize = 1 << mappingPageBits
+	switch m := any(&s.RuneMapping).(type) {
+	case *rangeMapping:
+		for _, e := range runes {
+			if n := len(m.ranges); n != 0 {
+				last := &m.ranges[n-1]
+				if e.r == last.start+rune(last.n) && e.i == uint(last.index+last.n) {
+					last.n++
+					continue
+				}
+			}
+			m.ranges = append(m.ranges, runeRange{start: e.r, n: 1, index: uint32(e.i)})
+		}
+	case *lutMapping:
+		m.min = s.MinRune
+		m.table = make([]dataIndex, s.MaxRune-s.MinRune+1)
+		for _, e := range runes {
+			m.table[e.r-m.min] = dataIndex(e.i + 1)
+		}
+	case *pageMapping:
+		m.min = s.MinRune
+		m.pages = make([]dataIndex, (s.MaxRune-s.MinRune)/pageSize+1)
+		m.entries = make([]dataIndex, pageSize) // The shared empty page
+		for _, e := range runes {
+			i := int(e.r - m.min)
+			p := i / pageSize
+			if m.pages[p] == 0 {
+				m.pages[p] = dataIndex(len(m.entries) / pageSize)
+				m.entries = append(m.entries, make([]dataIndex, pageSize)...)
+			}
+			m.entries[int(m.pages[p])*pageSize+i%pageSize] = dataIndex(e.i + 1)
+		}
+	}
+}
+
+// newTestFont creates a 2x2 font that defines 'a', 'b' and 'd' runes.
+// The 'c' rune is a gap inside the [MinRune, MaxRune] range.
+//
+// Every glyph has exactly one opaque pixel, the stub is fully opaque.
+// The missing runes are handled by the package missing glyph strategy,
+// unless the test sets the font onMissing field.
+func newTestFont() *bitmapFont {
+	data := []byte{
+		0b0010_0001, // 'a' (top-left), 'b' (top-right)
+		0b1111_0100, // 'd' (bottom-left), stub
+	}
+	core := &fontSegment{
+		img: newBitmapImage(data, 2, 2),
+		GlyphMetrics: []glyphMetrics{
+			{0, 0, 1, 1, 2},
+			{1, 0, 2, 1, 2},
+			{0, 1, 1, 2, 2},
+			{0, 0, 2, 2, 2},
+			{0, 0, 0, 0, 0}, // A zero-width glyph
+		},
+	}
+	initTestMapping(core, testRune{'a', 0}, testRune{'b', 1}, testRune{'d', 2})
+	f := newBitmapFont(0, core, 0, 2)
+	f.StubIndex = 3
+	return f
+}
+
+func TestMissingGlyph(t *testing.T) {
+	runes := []struct {
+		r       rune
+		defined bool
+	}{
+		{'a', true},
+		{'b', true},
+		{'c', false}, // A gap
+		{'d', true},
+		{'A', false}, // Below MinRune
+		{'z', false}, // Above MaxRune
+	}
+
+	for _, strategy := range []string{"emptymask", "stub", "panic"} {
+		newFont := func() *bitmapFont {
+			f := newTestFont()
+			f.onMissing = missingStrategy(strategy)
+			return f
+		}
+		faces := map[string]font.Face{
+			"bitmap": newFont(),
+			"scaled": Scale(newFont(), 2),
+			"cell":   CellBounds(newFont()),
+		}
+		for faceName, f := range faces {
+			for _, test := range runes {
+				if !test.defined && strategy == "panic" {
+					checkPanics(t, faceName, test.r, f)
+					continue
+				}
+
+				dr, mask, maskp, glyphAdvance, glyphOK := f.Glyph(fixed.Point26_6{}, test.r)
+				advance, advanceOK := f.GlyphAdvance(test.r)
+				bounds, boundsAdvance, boundsOK := f.GlyphBounds(test.r)
+
+				wantOK := test.defined || strategy == "stub"
+				if glyphOK != wantOK || advanceOK != wantOK || boundsOK != wantOK {
+					t.Fatalf("%s/%s/%q: Glyph ok=%v, GlyphAdvance ok=%v, GlyphBounds ok=%v, want %v",
+						strategy, faceName, test.r, glyphOK, advanceOK, boundsOK, wantOK)
+				}
+				if !wantOK {
+					continue
+				}
+				if glyphAdvance != advance || boundsAdvance != advance {
+					t.Fatalf("%s/%s/%q: advances mismatch: Glyph=%v GlyphAdvance=%v GlyphBounds=%v",
+						strategy, faceName, test.r, glyphAdvance, advance, boundsAdvance)
+				}
+				boundsRect := image.Rect(bounds.Min.X.Floor(), bounds.Min.Y.Floor(), bounds.Max.X.Ceil(), bounds.Max.Y.Ceil())
+				if !boundsRect.In(dr) {
+					t.Fatalf("%s/%s/%q: GlyphBounds %v are outside of the Glyph rectangle %v",
+						strategy, faceName, test.r, boundsRect, dr)
+				}
+				// The stub is the only glyph with opaque bottom-right pixel.
+				corner := maskp.Add(dr.Size()).Sub(image.Pt(1, 1))
+				isStub := mask.At(corner.X, corner.Y) != color.Color(colorZero)
+				if isStub == test.defined {
+					t.Fatalf("%s/%s/%q: got stub=%v", strategy, faceName, test.r, isStub)
+				}
+			}
+		}
+	}
+}
+
+func TestGlyphBounds(t *testing.T) {
+	f := newTestFont()
+
+	type glyphBoundsTest struct {
+		r    rune
+		want fixed.Rectangle26_6
+	}
+	tests := []glyphBoundsTest{
+		{'a', fixed.R(0, -2, 1, -1)},
+		{'b', fixed.R(1, -2, 2, -1)},
+		{'d', fixed.R(0, -1, 1, 0)},
+	}
+	// The stub is only used with the "stub" missing glyph strategy.
+	if onMissing == "stub" {
+		tests = append(tests, glyphBoundsTest{'c', fixed.R(0, -2, 2, 0)})
+	}
+	for _, test := range tests {
+		bounds, _, _ := f.GlyphBounds(test.r)
+		if bounds != test.want {
+			t.Errorf("GlyphBounds(%q):\nhave: %v\nwant: %v", test.r, bounds, test.want)
+		}
+		cellBounds, _, _ := CellBounds(f).GlyphBounds(test.r)
+		if want := fixed.R(0, -2, 2, 0); cellBounds != want {
+			t.Errorf("CellBounds GlyphBounds(%q):\nhave: %v\nwant: %v", test.r, cellBounds, want)
+		}
+	}
+}
+
+func TestZeroWidthIgnorables(t *testing.T) {
+	f := newTestFont()
+	f.ZeroWidthIndex = 4
+
+	faces := map[string]font.Face{
+		"bitmap": f,
+		"spaced": WithSpacing(f, 1, 0),
+		"scaled": Scale(WithSpacing(f, 1, 0), 2),
+	}
+	for faceName, f := range faces {
+		for _, r := range []rune{'\n', '\u00ad', '\u200d', '\ufe0f', '\U000e0001'} {
+			_, _, _, glyphAdvance, ok := f.Glyph(fixed.Point26_6{}, r)
+			if !ok || glyphAdvance != 0 {
+				t.Fatalf("%s: Glyph(%q): ok=%v advance=%v", faceName, r, ok, glyphAdvance)
+			}
+			advance, ok := f.GlyphAdvance(r)
+			if !ok || advance != 0 {
+				t.Fatalf("%s: GlyphAdvance(%q): ok=%v advance=%v", faceName, r, ok, advance)
+			}
+			if kern := f.Kern('a', r); kern != 0 {
+				t.Fatalf("%s: Kern('a', %q): %v", faceName, r, kern)
+			}
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	// The core segment has only a stub.
+	core := &fontSegment{
+		img:          newBitmapImage([]byte{0b1111}, 2, 2),
+		MinRune:      0,
+		MaxRune:      -1,
+		GlyphMetrics: []glyphMetrics{{0, 0, 2, 2, 2}},
+	}
+	newSegment := func(r rune) *fontSegment {
+		seg := &fontSegment{
+			img:          newBitmapImage([]byte{0b0001}, 2, 2),
+			GlyphMetrics: []glyphMetrics{{0, 0, 1, 1, 1}},
+		}
+		initTestMapping(seg, testRune{r, 0})
+		return seg
+	}
+	segments := []tagSegment{
+		{tag: "en", seg: newSegment('x')},
+		{tag: "ru", seg: newSegment('ж')},
+		{tag: "@compose", seg: newSegment('é')},
+	}
+
+	tests := []struct {
+		opts    []Option
+		defined string
+	}{
+		{nil, "xжé"},
+		{[]Option{WithTags("en", "ru")}, "xжé"},
+		{[]Option{WithTags("en")}, "xé"},
+		{[]Option{WithTags("ru"), WithTags("en")}, "xжé"},
+		{[]Option{WithTags()}, "é"},
+	}
+	for i, test := range tests {
+		f := newBitmapFont(0, core, 0, 2)
+		f.load([]string{"en", "ru"}, segments, test.opts)
+		for _, r := range "xжé" {
+			// The missing glyph strategy is not applied here.
+			defined := false
+			for _, s := range f.segments {
+				if _, ok := s.getRuneDataIndex(r); ok {
+					defined = true
+				}
+			}
+			if want := strings.ContainsRune(test.defined, r); defined != want {
+				t.Errorf("test%d: %q: have defined=%v, want %v", i, r, defined, want)
+			}
+		}
+	}
+}
+
+func TestWithTagsPanic(t *testing.T) {
+	core := &fontSegment{
+		img:          newBitmapImage([]byte{0b1111}, 2, 2),
+		MinRune:      0,
+		MaxRune:      -1,
+		GlyphMetrics: []glyphMetrics{{0, 0, 2, 2, 2}},
+	}
+
+	tests := []struct {
+		tags []string
+		opts []Option
+		want string
+	}{
+		{[]string{"en"}, []Option{WithTags("en", "ru")}, `WithTags: the font has no "ru" tag`},
+		{[]string{"en"}, []Option{WithTags("@whitespace")}, `WithTags: the font has no "@whitespace" tag`},
+		{nil, []Option{WithTags("en")}, `WithTags: the font is generated without the tag segments`},
+		{nil, []Option{WithTags()}, `WithTags: the font is generated without the tag segments`},
+	}
+	for i, test := range tests {
+		have := func() (msg any) {
+			defer func() {
+				msg = recover()
+			}()
+			newBitmapFont(0, core, 0, 2).load(test.tags, nil, test.opts)
+			return nil
+		}()
+		if have != test.want {
+			t.Errorf("test%d: have panic %v, want %q", i, have, test.want)
+		}
+	}
+
+	// A font without the tag segments can still be allocated without WithTags.
+	newBitmapFont(0, core, 0, 2).load(nil, nil, []Option{WithAlphaMasks()})
+}
+
+// compressBitmap encodes the atlas data of numGlyphs w×h glyphs like the generator does.
+// When the bitmaps are cropped, every glyph rectangle is its whole cell.
+func compressBitmap(data []byte, w, h, numGlyphs int) string {
+	if croppedBitmaps {
+		var headers []byte
+		for i := 0; i < numGlyphs; i++ {
+			headers = append(headers, 0, 0, byte(w), byte(h))
+		}
+		data = append(headers, data...)
+	}
+	return compressData(data)
+}
+
+// compressData encodes the data using the package codec.
+func compressData(data []byte) string {
+	return packageEncoder()(data)
+}
+
+// testEncoders implement the generator codecs: gzip, deflate, rle and none.
+var testEncoders = []func([]byte) string{
+	func(data []byte) string {
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		w.Write(data)
+		w.Close()
+		return compressed.String()
+	},
+	func(data []byte) string {
+		var compressed bytes.Buffer
+		w, _ := flate.NewWriter(&compressed, flate.DefaultCompression)
+		w.Write(data)
+		w.Close()
+		return compressed.String()
+	},
+	func(data []byte) string {
+		// Literal packets are enough for the tests.
+		var compressed bytes.Buffer
+		for len(data) != 0 {
+			n := min(len(data), 0x80)
+			compressed.WriteByte(byte(n - 1))
+			compressed.Write(data[:n])
+			data = data[n:]
+		}
+		return compressed.String()
+	},
+	func(data []byte) string {
+		return string(data)
+	},
+}
+
+// packageEncoder returns the encoder of the package codec.
+// The generator selects the codec by injecting the uncompress function,
+// so the encoder is found by checking which encoding uncompress can read.
+var packageEncoder = sync.OnceValue(func() func([]byte) string {
+	probe := []byte("probe data: \x00\x00\x00\x00\xff")
+	for _, encode := range testEncoders {
+		if decodes(encode(probe), probe) {
+			return encode
+		}
+	}
+	panic("no encoder matches the package codec")
+})
+
+func decodes(encoded string, want []byte) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return bytes.Equal(uncompress(encoded), want)
+}
+
+func TestUncropBitmap(t *testing.T) {
+	// Three 3x3 glyphs, the second one is empty.
+	data := []byte{
+		1, 0, 1, 2, // A vertical line at x=1
+		0, 0, 0, 0,
+		0, 1, 3, 2, // A 3x2 rectangle at y=1
+		0b0101_0111,
+	}
+	img := newBitmapImage(uncropBitmap(data, 3, 3, 3), 3, 3)
+	if img.Bounds() != image.Rect(0, 0, 3, 9) {
+		t.Fatalf("unexpected bounds: %v", img.Bounds())
+	}
+	want := map[image.Point]bool{
+		{1, 0}: true,
+		{1, 1}: true,
+		{0, 7}: true,
+		{2, 7}: true,
+		{1, 8}: true,
+	}
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 3; x++ {
+			have := img.AlphaAt(x, y).A != 0
+			if have != want[image.Pt(x, y)] {
+				t.Fatalf("pixel (%d, %d): have %v, want %v", x, y, have, want[image.Pt(x, y)])
+			}
+		}
+	}
+}
+
+func TestLazyData(t *testing.T) {
+	// The segment is shared by several fonts, just like the generated ones.
+	core := &fontSegment{
+		img:          newCompressedBitmapImage(compressBitmap([]byte{0b0010_0001, 0b1111_0100}, 2, 2, 4), 2, 2, 4),
+		GlyphMetrics: []glyphMetrics{{0, 0, 1, 1, 2}, {1, 0, 2, 1, 2}},
+	}
+	initTestMapping(core, testRune{'a', 0}, testRune{'b', 1})
+	fonts := make([]*bitmapFont, 4)
+	for i := range fonts {
+		fonts[i] = newBitmapFont(0, core, 0, 2)
+		fonts[i].load(nil, nil, []Option{WithAlphaMasks()})
+	}
+
+	if _, _, ok := fonts[0].GlyphBounds('a'); !ok {
+		t.Fatal("GlyphBounds('a') failed")
+	}
+	if core.img.data != nil || core.img.alphaMask != nil {
+		t.Fatal("GlyphBounds decompressed the data")
+	}
+	if bounds := core.img.Bounds(); bounds != image.Rect(0, 0, 2, 8) {
+		t.Fatalf("bounds before the decompression: have %v, want %v", bounds, image.Rect(0, 0, 2, 8))
+	}
+
+	var wg sync.WaitGroup
+	masks := make([]image.Image, len(fonts))
+	for i, f := range fonts {
+		wg.Add(1)
+		go func(i int, f *bitmapFont) {
+			defer wg.Done()
+			_, masks[i], _, _, _ = f.Glyph(fixed.Point26_6{}, 'b')
+		}(i, f)
+	}
+	wg.Wait()
+
+	for i, mask := range masks {
+		if mask != masks[0] {
+			t.Fatalf("font%d: the alpha mask is not shared", i)
+		}
+	}
+	if have := core.img.AlphaAt(1, 2); have.A != 0xff {
+		t.Fatalf("'b' pixel: have %v, want opaque", have)
+	}
+}
+
+// newPagedTestFont is newTestFont that stores its glyphs in pages of 2 glyphs.
+func newPagedTestFont(cacheSize int, opts ...Option) *bitmapFont {
+	page0 := compressBitmap([]byte{0b0010_0001}, 2, 2, 2) // 'a', 'b'
+	page1 := compressBitmap([]byte{0b1111_0100}, 2, 2, 2) // 'd', stub
+	compressed := page0 + page1
+	offsets := []uint32{0, uint32(len(page0)), uint32(len(compressed))}
+
+	f := newTestFont()
+	f.core.img = nil
+	f.core.pages = newBitmapPages(compressed, offsets, 2, 2, 4, 2, cacheSize)
+	f.load(nil, nil, opts)
+	return f
+}
+
+func TestPagedBitmap(t *testing.T) {
+	faces := []struct {
+		name  string
+		f     font.Face
+		paged font.Face
+	}{
+		{"bitmap", newTestFont(), newPagedTestFont(0)},
+		{"cached", newTestFont(), newPagedTestFont(1)},
+		{"alpha", newTestFont(), newPagedTestFont(1, WithAlphaMasks())},
+		{"scaled", Scale(newTestFont(), 2), Scale(newPagedTestFont(1), 2)},
+	}
+	for _, face := range faces {
+		for _, r := range "abdab" {
+			dr, mask, maskp, advance, _ := face.f.Glyph(fixed.Point26_6{}, r)
+			pagedDR, pagedMask, pagedMaskp, pagedAdvance, _ := face.paged.Glyph(fixed.Point26_6{}, r)
+			if dr != pagedDR || advance != pagedAdvance {
+				t.Fatalf("%s: %q: have %v/%v, want %v/%v", face.name, r, pagedDR, pagedAdvance, dr, advance)
+			}
+			for y := 0; y < dr.Dy(); y++ {
+				for x := 0; x < dr.Dx(); x++ {
+					have := color.AlphaModel.Convert(pagedMask.At(pagedMaskp.X+x, pagedMaskp.Y+y))
+					want := color.AlphaModel.Convert(mask.At(maskp.X+x, maskp.Y+y))
+					if have != want {
+						t.Fatalf("%s: %q: mask at %d,%d: have %v, want %v", face.name, r, x, y, have, want)
+					}
+				}
+			}
+		}
+
+		have := image.NewRGBA(image.Rect(0, 0, 20, 8))
+		want := image.NewRGBA(have.Bounds())
+		DrawString(face.paged, have, 0, 4, "abdab", color.White)
+		DrawString(face.f, want, 0, 4, "abdab", color.White)
+		if !bytes.Equal(have.Pix, want.Pix) {
+			t.Fatalf("%s: DrawString results differ", face.name)
+		}
+	}
+
+	f := newPagedTestFont(1)
+	f.Glyph(fixed.Point26_6{}, 'a')
+	f.Glyph(fixed.Point26_6{}, 'd')
+	pages := f.core.pages
+	if pages.pages[0].Load() != nil || pages.pages[1].Load() == nil {
+		t.Fatalf("the page cache is not bounded")
+	}
+}
+
+func TestRuneMapping(t *testing.T) {
+	// Several ranges, gaps, pages and data indices that are not consecutive.
+	var runes []testRune
+	want := make(map[rune]uint)
+	index := uint(0)
+	for r := rune(0x20); r < 0x2000; r++ {
+		switch {
+		case r < 0x7f, r >= 0x400 && r < 0x450:
+			index++
+		case r%7 == 0:
+			index += 2
+		case r == 0x1234:
+			index = 0 // An alias of the first rune
+		default:
+			continue
+		}
+		runes = append(runes, testRune{r, index})
+		want[r] = index
+	}
+	var seg fontSegment
+	initTestMapping(&seg, runes...)
+
+	check := func(r rune) {
+		index, ok := seg.getRuneDataIndex(r)
+		wantIndex, wantOK := want[r]
+		if ok != wantOK || index != wantIndex {
+			t.Fatalf("getRuneDataIndex(%#x): have %d (ok=%v), want %d (ok=%v)", r, index, ok, wantIndex, wantOK)
+		}
+	}
+	// Both the sequential and the random access patterns are checked,
+	// since the lookup can depend on the previous results.
+	for r := rune(0); r < 0x2100; r++ {
+		check(r)
+	}
+	for r := rune(0x2100); r >= 0; r -= 97 {
+		check(r)
+		check(0x2100 - r)
+	}
+}
+
+// encodeTestTables encodes the segment tables the same way the generator does.
+func encodeTestTables(s *fontSegment) string {
+	var data []byte
+	putUint := func(v uint) {
+		data = binary.AppendUvarint(data, uint64(v))
+	}
+	putIndices := func(indices []dataIndex) {
+		putUint(uint(len(indices)))
+		for _, v := range indices {
+			putUint(uint(v))
+		}
+	}
+
+	switch m := any(&s.RuneMapping).(type) {
+	case *rangeMapping:
+		putUint(uint(len(m.ranges)))
+		end := rune(0)
+		for _, rr := range m.ranges {
+			putUint(uint(rr.start - end))
+			putUint(uint(rr.n))
+			putUint(uint(rr.index))
+			end = rr.start + rune(rr.n)
+		}
+	case *lutMapping:
+		data = binary.AppendVarint(data, int64(m.min))
+		putIndices(m.table)
+	case *pageMapping:
+		data = binary.AppendVarint(data, int64(m.min))
+		putIndices(m.pages)
+		putIndices(m.entries)
+	}
+
+	putUint(uint(len(s.GlyphMetrics)))
+	for _, m := range s.GlyphMetrics {
+		putUint(uint(m.minX))
+		putUint(uint(m.minY))
+		putUint(uint(m.maxX))
+		putUint(uint(m.maxY))
+		putUint(uint(m.advance))
+	}
+
+	putUint(uint(len(s.Anchors)))
+	prevRune := rune(0)
+	for _, a := range s.Anchors {
+		putUint(uint(a.r - prevRune))
+		data = append(data, a.flags)
+		if a.flags&anchorTop != 0 {
+			data = append(data, byte(a.top[0]), byte(a.top[1]))
+		}
+		if a.flags&anchorBottom != 0 {
+			data = append(data, byte(a.bottom[0]), byte(a.bottom[1]))
+		}
+		if a.flags&(anchorMarkTop|anchorMarkBottom) != 0 {
+			data = append(data, byte(a.mark[0]), byte(a.mark[1]))
+		}
+		prevRune = a.r
+	}
+
+	return compressData(data)
+}
+
+func TestBinaryTables(t *testing.T) {
+	var runes []testRune
+	for r := rune(0x20); r < 0x800; r += 3 {
+		runes = append(runes, testRune{r, uint(r % 5)})
+	}
+	literal := &fontSegment{
+		img: newBitmapImage([]byte{0b0001}, 2, 2),
+		GlyphMetrics: []glyphMetrics{
+			{0, 0, 1, 1, 2},
+			{1, 0, 2, 1, 2},
+			{0, 1, 1, 2, 1},
+			{0, 0, 2, 2, 2},
+			{0, 0, 0, 0, 0},
+		},
+		Anchors: []glyphAnchors{
+			{r: 'a', flags: anchorTop | anchorBottom, top: [2]int8{1, -1}, bottom: [2]int8{1, 2}},
+			{r: '\u0301', flags: anchorMarkTop, mark: [2]int8{0, 1}},
+			{r: '\u0323', flags: anchorMarkBottom | anchorBottom, bottom: [2]int8{0, 3}, mark: [2]int8{-1, 0}},
+		},
+	}
+	initTestMapping(literal, runes...)
+
+	encoded := &fontSegment{
+		img:     literal.img,
+		MinRune: literal.MinRune,
+		MaxRune: literal.MaxRune,
+		tables:  encodeTestTables(literal),
+	}
+	// The tables are decoded when the segment is added to a font.
+	f := newBitmapFont(0, encoded, 0, 2)
+	if !f.hasAnchors {
+		t.Fatal("the font anchors are not loaded")
+	}
+
+	for r := rune(0); r < 0x900; r++ {
+		index, ok := encoded.getRuneDataIndex(r)
+		wantIndex, wantOK := literal.getRuneDataIndex(r)
+		if ok != wantOK || index != wantIndex {
+			t.Fatalf("getRuneDataIndex(%#x): have %d (ok=%v), want %d (ok=%v)", r, index, ok, wantIndex, wantOK)
+		}
+	}
+	for i, want := range literal.GlyphMetrics {
+		if have := encoded.GlyphMetrics[i]; have != want {
+			t.Fatalf("metrics[%d]: have %v, want %v", i, have, want)
+		}
+	}
+	if len(encoded.Anchors) != len(literal.Anchors) {
+		t.Fatalf("have %d anchors, want %d", len(encoded.Anchors), len(literal.Anchors))
+	}
+	for i, want := range literal.Anchors {
+		if have := encoded.Anchors[i]; have != want {
+			t.Fatalf("anchors[%d]: have %v, want %v", i, have, want)
+		}
+	}
+
+	// Adding the segment to another font doesn't decode it again.
+	metrics := encoded.GlyphMetrics
+	newBitmapFont(0, encoded, 0, 2)
+	if &encoded.GlyphMetrics[0] != &metrics[0] {
+		t.Fatal("the tables are decoded twice")
+	}
+}
+
+// TestConcurrentUse is meant to be run with the race detector.
+func TestConcurrentUse(t *testing.T) {
+	f := newTestFont()
+	marks := &fontSegment{
+		img:          newBitmapImage([]byte{0b0001}, 2, 2),
+		GlyphMetrics: []glyphMetrics{{0, 0, 1, 1, 2}},
+		Anchors: []glyphAnchors{
+			{r: 'a', flags: anchorTop, top: [2]int8{1, 0}},
+			{r: '\u0301', flags: anchorMarkTop, mark: [2]int8{0, 1}},
+		},
+	}
+	initTestMapping(marks, testRune{'\u0301', 0})
+	f.addSegment(marks)
+	paged := newPagedTestFont(1)
+	paged.addSegment(marks)
+
+	faces := map[string]font.Face{
+		"bitmap": f,
+		"scaled": Scale(f, 2),
+		"spaced": WithSpacing(f, 1, 1),
+		// The pages are loaded and evicted all the time.
+		"paged": Scale(paged, 2),
+	}
+	const text = "abd\u0301a\u0301dba"
+	for faceName, f := range faces {
+		type glyphResult struct {
+			bounds  fixed.Rectangle26_6
+			advance fixed.Int26_6
+		}
+		want := make(map[rune]glyphResult)
+		for _, r := range text {
+			bounds, advance, _ := f.GlyphBounds(r)
+			want[r] = glyphResult{bounds: bounds, advance: advance}
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					prev := rune(-1)
+					for _, r := range text {
+						if prev != -1 {
+							f.Kern(prev, r)
+						}
+						prev = r
+						_, _, _, glyphAdvance, _ := f.Glyph(fixed.Point26_6{}, r)
+						bounds, advance, _ := f.GlyphBounds(r)
+						if have := (glyphResult{bounds: bounds, advance: advance}); have != want[r] || glyphAdvance != advance {
+							t.Errorf("%s: %q: have %v, want %v", faceName, r, have, want[r])
+							return
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// newAnchorsTestFont creates a 3x6 font with the x-height of 2
+// and the baseline at y=4 that defines the marks with anchors.
+// Every glyph is a single pixel, except for 'A' that is a vertical line.
+func newAnchorsTestFont() *bitmapFont {
+	const w, h = 3, 6
+	pixels := [][]image.Point{
+		{{1, 1}, {1, 2}, {1, 3}, {1, 4}}, // 'A'
+		{{1, 4}},                         // 'a'
+		{{1, 4}},                         // 'x', it has no anchors
+		{{1, 0}},                         // U+0301 acute
+		{{1, 5}},                         // U+0323 dot below
+	}
+	data := make([]byte, (len(pixels)*w*h+7)/8)
+	for glyph, points := range pixels {
+		for _, p := range points {
+			i := glyph*w*h + p.Y*w + p.X
+			data[i/8] |= 1 << (i % 8)
+		}
+	}
+	core := &fontSegment{
+		img: newBitmapImage(data, w, h),
+		GlyphMetrics: []glyphMetrics{
+			{1, 1, 2, 5, 3},
+			{1, 4, 2, 5, 3},
+			{1, 4, 2, 5, 3},
+			{1, 0, 2, 1, 3},
+			{1, 5, 2, 6, 3},
+		},
+		Anchors: []glyphAnchors{
+			{r: 'A', flags: anchorTop, top: [2]int8{1, 0}},
+			{r: 'a', flags: anchorTop | anchorBottom, top: [2]int8{1, 3}, bottom: [2]int8{1, 5}},
+			{r: '\u0301', flags: anchorMarkTop, mark: [2]int8{1, 0}},
+			{r: '\u0323', flags: anchorMarkBottom, mark: [2]int8{1, 5}},
+		},
+	}
+	initTestMapping(core,
+		testRune{'A', 0}, testRune{'a', 1}, testRune{'x', 2},
+		testRune{'\u0301', 3}, testRune{'\u0323', 4})
+	f := newBitmapFont(0, core, 0, 4)
+	f.XHeight = 2
+	return f
+}
+
+func TestMarkAnchors(t *testing.T) {
+	pts := func(points ...image.Point) []image.Point { return points }
+	capitalA := pts(image.Pt(1, 1), image.Pt(1, 2), image.Pt(1, 3), image.Pt(1, 4))
+
+	// The font.Drawer marks are placed over the default base (right above
+	// the x-height or right below the baseline), DrawString uses the base anchors.
+	tests := []struct {
+		text       string
+		drawer     []image.Point
+		drawString []image.Point
+	}{
+		{"a\u0301", pts(image.Pt(1, 4), image.Pt(1, 2)), pts(image.Pt(1, 4), image.Pt(1, 3))},
+		{"A\u0301", append(pts(image.Pt(1, 2)), capitalA...), append(pts(image.Pt(1, 0)), capitalA...)},
+		{"x\u0301", pts(image.Pt(1, 4), image.Pt(1, 2)), pts(image.Pt(1, 4), image.Pt(1, 2))},
+		{"a\u0323", pts(image.Pt(1, 4), image.Pt(1, 5)), pts(image.Pt(1, 4), image.Pt(1, 5))},
+		{"xa\u0301", pts(image.Pt(1, 4), image.Pt(4, 4), image.Pt(4, 2)), pts(image.Pt(1, 4), image.Pt(4, 4), image.Pt(4, 3))},
+		// Stacked marks.
+		{"a\u0301\u0301", pts(image.Pt(1, 4), image.Pt(1, 2)), pts(image.Pt(1, 4), image.Pt(1, 3), image.Pt(1, 2))},
+		{"a\u0323\u0301", pts(image.Pt(1, 4), image.Pt(1, 5), image.Pt(1, 2)), pts(image.Pt(1, 4), image.Pt(1, 5), image.Pt(1, 3))},
+		{"A\u0301a\u0301", append(pts(image.Pt(1, 2), image.Pt(4, 4), image.Pt(4, 2)), capitalA...), append(pts(image.Pt(1, 0), image.Pt(4, 4), image.Pt(4, 3)), capitalA...)},
+	}
+
+	for _, scale := range []int{1, 2} {
+		f := Scale(newAnchorsTestFont(), uint(scale))
+		// The pixels are scaled, but the dot is always at the same cell row.
+		dotY := 4 * scale
+		draw := func(drawFunc func(dst *image.Alpha)) (pixels map[image.Point]bool, ink image.Rectangle) {
+			dst := image.NewAlpha(image.Rect(0, 0, 10*scale, 8*scale))
+			drawFunc(dst)
+			pixels = make(map[image.Point]bool)
+			for y := 0; y < dst.Rect.Dy(); y++ {
+				for x := 0; x < dst.Rect.Dx(); x++ {
+					if dst.AlphaAt(x, y).A != 0 {
+						pixels[image.Pt(x, y)] = true
+						ink = ink.Union(image.Rect(x, y, x+1, y+1))
+					}
+				}
+			}
+			return pixels, ink.Sub(image.Pt(0, dotY))
+		}
+		check := func(method, text string, have map[image.Point]bool, want []image.Point) {
+			t.Helper()
+			// The glyphs can overlap.
+			wantSet := make(map[image.Point]bool)
+			for _, p := range want {
+				wantSet[p] = true
+			}
+			if len(have) != len(wantSet)*scale*scale {
+				t.Fatalf("scale%d/%s(%q): have %d pixels, want %d", scale, method, text, len(have), len(wantSet)*scale*scale)
+			}
+			for p := range wantSet {
+				if !have[p.Mul(scale)] {
+					t.Fatalf("scale%d/%s(%q): pixel %v is not drawn", scale, method, text, p)
+				}
+			}
+		}
+		toFixed := func(r image.Rectangle) fixed.Rectangle26_6 {
+			return fixed.R(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y)
+		}
+
+		for _, test := range tests {
+			pixels, ink := draw(func(dst *image.Alpha) {
+				d := font.Drawer{Dst: dst, Src: image.White, Face: f, Dot: fixed.P(0, dotY)}
+				d.DrawString(test.text)
+			})
+			check("Drawer", test.text, pixels, test.drawer)
+			if bounds, _ := font.BoundString(f, test.text); bounds != toFixed(ink) {
+				t.Fatalf("scale%d/font.BoundString(%q):\nhave: %v\nwant: %v", scale, test.text, bounds, toFixed(ink))
+			}
+
+			// WithMarkAnchors makes font.Drawer match DrawString.
+			anchored := WithMarkAnchors(f)
+			pixels, ink = draw(func(dst *image.Alpha) {
+				d := font.Drawer{Dst: dst, Src: image.White, Face: anchored, Dot: fixed.P(0, dotY)}
+				d.DrawString(test.text)
+			})
+			check("anchored Drawer", test.text, pixels, test.drawString)
+			if bounds, _ := font.BoundString(anchored, test.text); bounds != toFixed(ink) {
+				t.Fatalf("scale%d/anchored font.BoundString(%q):\nhave: %v\nwant: %v", scale, test.text, bounds, toFixed(ink))
+			}
+
+			pixels, ink = draw(func(dst *image.Alpha) {
+				DrawString(f, dst, 0, dotY, test.text, color.White)
+			})
+			check("DrawString", test.text, pixels, test.drawString)
+			anchoredPixels, _ := draw(func(dst *image.Alpha) {
+				DrawString(anchored, dst, 0, dotY, test.text, color.White)
+			})
+			check("anchored DrawString", test.text, anchoredPixels, test.drawString)
+			bounds, advance := BoundString(f, test.text)
+			if bounds != toFixed(ink) {
+				t.Fatalf("scale%d/BoundString(%q):\nhave: %v\nwant: %v", scale, test.text, bounds, toFixed(ink))
+			}
+			if want := font.MeasureString(f, test.text); advance != want {
+				t.Fatalf("scale%d/BoundString(%q): have advance %v, want %v", scale, test.text, advance, want)
+			}
+		}
+	}
+}
+
+// TestConcurrentMarks checks that the marks placement doesn't depend
+// on the other goroutines drawing the text with the same face.
+// Run it with -race to catch any shared state.
+func TestConcurrentMarks(t *testing.T) {
+	f := newAnchorsTestFont()
+	faces := map[string]font.Face{
+		"bitmap": f,
+		"scaled": Scale(f, 2),
+		"spaced": WithSpacing(f, 1, 1),
+	}
+	const text = "A\u0301a\u0323\u0301xa\u0301\u0301A\u0323"
+	draws := map[string]func(f font.Face, dst *image.Alpha){
+		"Drawer": func(f font.Face, dst *image.Alpha) {
+			d := font.Drawer{Dst: dst, Src: image.White, Face: f, Dot: fixed.P(0, 8)}
+			d.DrawString(text)
+		},
+		"DrawString": func(f font.Face, dst *image.Alpha) {
+			DrawString(f, dst, 0, 8, text, color.White)
+		},
+	}
+	for faceName, f := range faces {
+		for drawName, drawFunc := range draws {
+			want := image.NewAlpha(image.Rect(0, 0, 64, 16))
+			drawFunc(f, want)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						have := image.NewAlpha(want.Rect)
+						drawFunc(f, have)
+						if !bytes.Equal(have.Pix, want.Pix) {
+							t.Errorf("%s/%s: the result differs from the single goroutine one", faceName, drawName)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		}
+	}
+}
+
+func TestGlyphAllocs(t *testing.T) {
+	faces := map[string]font.Face{
+		"bitmap": newTestFont(),
+		"scaled": Scale(newTestFont(), 2),
+		"spaced": WithSpacing(Scale(newTestFont(), 3), 1, 0),
+	}
+	for faceName, f := range faces {
+		allocs := testing.AllocsPerRun(100, func() {
+			for _, r := range "abd" {
+				f.Glyph(fixed.Point26_6{}, r)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%s: Glyph allocs: have %v, want 0", faceName, allocs)
+		}
+	}
+}
+
+func TestGlyphMask(t *testing.T) {
+	f := newTestFont()
+	scaled := Scale(f, 3)
+	for _, r := range "abd" {
+		dr, mask, maskp, _, _ := f.Glyph(fixed.Point26_6{}, r)
+		sdr, smask, smaskp, _, _ := scaled.Glyph(fixed.Point26_6{}, r)
+		if sdr.Dx() != dr.Dx()*3 || sdr.Dy() != dr.Dy()*3 {
+			t.Fatalf("%q: scaled dr: have %v, want %v*3", r, sdr, dr)
+		}
+		for y := 0; y < sdr.Dy(); y++ {
+			for x := 0; x < sdr.Dx(); x++ {
+				have := smask.At(smaskp.X+x, smaskp.Y+y)
+				want := mask.At(maskp.X+x/3, maskp.Y+y/3)
+				if have != want {
+					t.Fatalf("%q: scaled mask at %d,%d: have %v, want %v", r, x, y, have, want)
+				}
+			}
+		}
+	}
+}
+
+func TestMaskRGBA64At(t *testing.T) {
+	f := newTestFont()
+	_, mask, _, _, _ := f.Glyph(fixed.Point26_6{}, 'a')
+	_, scaledMask, _, _, _ := Scale(f, 3).Glyph(fixed.Point26_6{}, 'a')
+	masks := map[string]image.Image{
+		"bitmap": mask,
+		"scaled": scaledMask,
+	}
+	for name, m := range masks {
+		m64, ok := m.(image.RGBA64Image)
+		if !ok {
+			t.Fatalf("%s: %T doesn't implement image.RGBA64Image", name, m)
+		}
+		// Include the pixels outside of the bounds.
+		b := m.Bounds().Inset(-2)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, b, a := m.At(x, y).RGBA()
+				want := color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+				if have := m64.RGBA64At(x, y); have != want {
+					t.Fatalf("%s: RGBA64At(%d, %d): have %v, want %v", name, x, y, have, want)
+				}
+			}
+		}
+	}
+}
+
+func TestAlphaMasks(t *testing.T) {
+	f := newTestFont()
+	alphaFont := newTestFont()
+	alphaFont.load(nil, nil, []Option{WithAlphaMasks()})
+	for _, r := range "abd" {
+		dr, mask, maskp, _, _ := f.Glyph(fixed.Point26_6{}, r)
+		_, alphaMask, alphaMaskp, _, _ := alphaFont.Glyph(fixed.Point26_6{}, r)
+		if _, ok := alphaMask.(*image.Alpha); !ok {
+			t.Fatalf("%q: have %T mask, want *image.Alpha", r, alphaMask)
+		}
+		for y := 0; y < dr.Dy(); y++ {
+			for x := 0; x < dr.Dx(); x++ {
+				have := alphaMask.At(alphaMaskp.X+x, alphaMaskp.Y+y)
+				want := mask.At(maskp.X+x, maskp.Y+y)
+				if have != want {
+					t.Fatalf("%q: alpha mask at %d,%d: have %v, want %v", r, x, y, have, want)
+				}
+			}
+		}
+	}
+}
+
+// newBenchmarkFont creates an 8x16 font that defines 'a', 'b', 'c' and 'd' runes.
+func newBenchmarkFont(opts ...Option) *bitmapFont {
+	const numGlyphs = 4
+	data := make([]byte, numGlyphs*8*16/8)
+	for i := range data {
+		data[i] = byte(i * 37)
+	}
+	core := &fontSegment{
+		img: newBitmapImage(data, 8, 16),
+		GlyphMetrics: []glyphMetrics{
+			{0, 0, 8, 16, 8},
+			{0, 0, 8, 16, 8},
+			{0, 0, 8, 16, 8},
+			{0, 0, 8, 16, 8},
+		},
+	}
+	initTestMapping(core, testRune{'a', 0}, testRune{'b', 1}, testRune{'c', 2}, testRune{'d', 3})
+	f := newBitmapFont(0, core, 0, 12)
+	f.load(nil, nil, opts)
+	return f
+}
+
+func BenchmarkDrawGlyph(b *testing.B) {
+	faces := []struct {
+		name string
+		f    font.Face
+	}{
+		{"bitmap", newBenchmarkFont()},
+		{"alpha", newBenchmarkFont(WithAlphaMasks())},
+		{"scaled", Scale(newBenchmarkFont(), 2)},
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for _, face := range faces {
+		b.Run(face.name, func(b *testing.B) {
+			b.ReportAllocs()
+			dot := fixed.P(8, 24)
+			for i := 0; i < b.N; i++ {
+				dr, mask, maskp, _, _ := face.f.Glyph(dot, rune('a'+i%4))
+				draw.DrawMask(dst, dr, image.White, image.Point{}, mask, maskp, draw.Over)
+			}
+		})
+	}
+}
+
+func TestDrawString(t *testing.T) {
+	newImages := func() map[string]draw.Image {
+		bounds := image.Rect(-3, 0, 40, 20)
+		return map[string]draw.Image{
+			"rgba":     image.NewRGBA(bounds),
+			"nrgba":    image.NewNRGBA(bounds),
+			"paletted": image.NewPaletted(bounds, color.Palette{color.Black, color.White, color.NRGBA{R: 0xff, A: 0xff}}),
+			"alpha":    image.NewAlpha(bounds),
+			"gray":     image.NewGray(bounds),
+		}
+	}
+	// A mark without anchors is drawn at its own position by both methods.
+	marks := &fontSegment{
+		img:          newBitmapImage(bytes.Repeat([]byte{0b10011001}, 8*16/8), 8, 16),
+		GlyphMetrics: []glyphMetrics{{0, 0, 8, 16, 0}},
+	}
+	initTestMapping(marks, testRune{'\u0301', 0})
+	newFont := func() *bitmapFont {
+		f := newBenchmarkFont()
+		f.addSegment(marks)
+		return f
+	}
+	faces := map[string]font.Face{
+		"bitmap": newFont(),
+		"scaled": Scale(newFont(), 2),
+		"spaced": WithSpacing(newFont(), 1, 0),
+	}
+	colors := []color.Color{
+		color.White,
+		color.NRGBA{R: 0xff, A: 0xff},
+		color.NRGBA{R: 0xff, G: 0x80, A: 0x80}, // Translucent
+	}
+	// 'z' is a missing rune, it can't be drawn if the missing glyph is a panic.
+	text := "abcd\u0301zba"
+	if onMissing == "panic" {
+		text = "abcd\u0301ba"
+	}
+
+	for faceName, f := range faces {
+		for _, c := range colors {
+			// The translucent colors blending results may differ in rounding.
+			tolerance := 1
+			if _, _, _, a := c.RGBA(); a == 0xffff {
+				tolerance = 0
+			}
+			have := newImages()
+			want := newImages()
+			for imgName := range have {
+				// Start with a non-empty background, some glyphs are partially clipped.
+				for _, img := range []draw.Image{have[imgName], want[imgName]} {
+					draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{G: 0x40, A: 0x80}), image.Point{}, draw.Src)
+				}
+				DrawString(f, have[imgName], -1, 14, text, c)
+				d := font.Drawer{
+					Dst:  want[imgName],
+					Src:  image.NewUniform(c),
+					Face: f,
+					Dot:  fixed.P(-1, 14),
+				}
+				d.DrawString(text)
+
+				bounds := have[imgName].Bounds()
+				for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+					for x := bounds.Min.X; x < bounds.Max.X; x++ {
+						haveColor := have[imgName].At(x, y)
+						wantColor := want[imgName].At(x, y)
+						if !colorsClose(haveColor, wantColor, tolerance) {
+							t.Fatalf("%s/%s/%v: pixel at %d,%d: have %v, want %v",
+								faceName, imgName, c, x, y, haveColor, wantColor)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// colorsClose reports whether the 8-bit premultiplied channels
+// of the colors differ by at most the tolerance.
+func colorsClose(c1, c2 color.Color, tolerance int) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	for _, pair := range [...][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}, {a1, a2}} {
+		if d := int(pair[0]>>8) - int(pair[1]>>8); d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func BenchmarkDrawString(b *testing.B) {
+	const text = "abcdabcdabcdabcd"
+	f := newBenchmarkFont()
+	dst := image.NewRGBA(image.Rect(0, 0, 160, 32))
+	b.Run("drawer", func(b *testing.B) {
+		b.ReportAllocs()
+		d := font.Drawer{Dst: dst, Src: image.White, Face: f}
+		for i := 0; i < b.N; i++ {
+			d.Dot = fixed.P(0, 12)
+			d.DrawString(text)
+		}
+	})
+	b.Run("alphaDrawer", func(b *testing.B) {
+		b.ReportAllocs()
+		d := font.Drawer{Dst: dst, Src: image.White, Face: newBenchmarkFont(WithAlphaMasks())}
+		for i := 0; i < b.N; i++ {
+			d.Dot = fixed.P(0, 12)
+			d.DrawString(text)
+		}
+	})
+	b.Run("drawString", func(b *testing.B) {
+		b.ReportAllocs()
+		var c color.Color = color.White
+		for i := 0; i < b.N; i++ {
+			DrawString(f, dst, 0, 12, text, c)
+		}
+	})
+}
+
+func BenchmarkGlyph(b *testing.B) {
+	faces := []struct {
+		name string
+		f    font.Face
+	}{
+		{"bitmap", newTestFont()},
+		{"scaled", Scale(newTestFont(), 2)},
+	}
+	const text = "abd"
+	for _, face := range faces {
+		b.Run(face.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				face.f.Glyph(fixed.Point26_6{}, rune(text[i%len(text)]))
+			}
+		})
+	}
+}
+
+func checkPanics(t *testing.T, faceName string, r rune, f font.Face) {
+	t.Helper()
+
+	methods := map[string]func(){
+		"Glyph":        func() { f.Glyph(fixed.Point26_6{}, r) },
+		"GlyphAdvance": func() { f.GlyphAdvance(r) },
+		"GlyphBounds":  func() { f.GlyphBounds(r) },
+	}
+	for name, fn := range methods {
+		panicked := func() (panicked bool) {
+			defer func() {
+				panicked = recover() != nil
+			}()
+			fn()
+			return false
+		}()
+		if !panicked {
+			t.Fatalf("panic/%s/%q: %s didn't panic", faceName, r, name)
+		}
+	}
+}
+
+func TestMarkAnchorsDrawer(t *testing.T) {
+	// markRow returns the acute mark row drawn over the base.
+	// The base glyphs only have ink in the column 1, the mark is in the same column.
+	f := WithMarkAnchors(newAnchorsTestFont())
+	markRow := func(base rune) int {
+		withMark := image.NewAlpha(image.Rect(0, 0, 3, 6))
+		d := font.Drawer{Dst: withMark, Src: image.White, Face: f, Dot: fixed.P(0, 4)}
+		d.DrawString(string(base) + "\u0301")
+		baseOnly := image.NewAlpha(withMark.Rect)
+		d = font.Drawer{Dst: baseOnly, Src: image.White, Face: f, Dot: fixed.P(0, 4)}
+		d.DrawString(string(base))
+		for y := 0; y < 6; y++ {
+			if withMark.AlphaAt(1, y) != baseOnly.AlphaAt(1, y) {
+				return y
+			}
+		}
+		t.Fatalf("%q: the mark is not drawn", base)
+		return 0
+	}
+
+	// The anchors: 'a' top is at y=3, 'A' top is at y=0.
+	if have := markRow('a'); have != 3 {
+		t.Fatalf("a: have the mark at y=%d, want 3", have)
+	}
+	if have := markRow('A'); have != 0 {
+		t.Fatalf("A: have the mark at y=%d, want 0", have)
+	}
+}
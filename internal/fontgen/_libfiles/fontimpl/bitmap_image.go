@@ -3,40 +3,84 @@ package fontimpl
 import (
 	"image"
 	"image/color"
+	"sync"
+	"sync/atomic"
 )
 
-var (
-	colorZero = color.Alpha{0}
-	colorOne  = color.Alpha{0xff}
-)
+var colorZero = color.Alpha{0}
 
+// bitmapImage is a 1-bit image of the glyphs atlas:
+// all glyphs are stacked vertically, so the glyph with data index i
+// occupies the [i*height, (i+1)*height) rows.
+//
+// The atlas is used as a mask directly, the glyph is selected by the maskp
+// returned from the Glyph method. This way, Glyph never allocates.
+//
+// The atlas data can be decompressed lazily, see [bitmapImage.load].
 type bitmapImage struct {
 	data   []byte
-	width  uint
-	height uint
-	offset uint
+	width  uint // The glyph cell width
+	height uint // The glyph cell height
 	bounds image.Rectangle
+
+	loadOnce   sync.Once
+	compressed string
+
+	// alphaMask is an expanded version of the atlas, see WithAlphaMasks.
+	// It's created on the first use.
+	alphaOnce sync.Once
+	alphaMask *image.Alpha
+
+	// scaledMask is the last scaled version of the atlas, see scaled.
+	scaledMask atomic.Pointer[scaledImage]
 }
 
 func newBitmapImage(data []byte, w, h int) *bitmapImage {
 	// data is expected to be uncompressed.
+	numGlyphs := len(data) * 8 / (w * h)
 	return &bitmapImage{
 		width:  uint(w),
 		height: uint(h),
 		data:   data,
-		bounds: image.Rect(0, 0, w, h),
+		bounds: image.Rect(0, 0, w, h*numGlyphs),
 	}
 }
 
-func (img *bitmapImage) WithOffset(offset uint) *bitmapImage {
+// newCompressedBitmapImage is like newBitmapImage, but the data
+// is decompressed only when the atlas is used for the first time.
+func newCompressedBitmapImage(compressed string, w, h, numGlyphs int) *bitmapImage {
 	return &bitmapImage{
-		data:   img.data,
-		width:  img.width,
-		offset: offset,
-		bounds: img.bounds,
+		width:      uint(w),
+		height:     uint(h),
+		compressed: compressed,
+		bounds:     image.Rect(0, 0, w, h*numGlyphs),
 	}
 }
 
+// load decompresses the atlas data if it's not done yet.
+// It's safe to call it from several goroutines at once.
+//
+// The data field can only be accessed after the load call;
+// the bounds and the glyph cell sizes are always available.
+func (img *bitmapImage) load() {
+	// This method is called for every drawn glyph,
+	// so it's kept small enough to be inlined.
+	img.loadOnce.Do(img.decompress)
+}
+
+func (img *bitmapImage) decompress() {
+	if img.data == nil {
+		numGlyphs := img.bounds.Dy() / int(img.height)
+		img.data = decodeBitmap(img.compressed, int(img.width), int(img.height), numGlyphs)
+		img.compressed = ""
+	}
+}
+
+// glyphOrigin returns the atlas position of the glyph with the given data index.
+func (img *bitmapImage) glyphOrigin(index uint) image.Point {
+	return image.Pt(0, int(index*img.height))
+}
+
 func (img *bitmapImage) ColorModel() color.Model {
 	return color.AlphaModel
 }
@@ -46,16 +90,43 @@ func (img *bitmapImage) Bounds() image.Rectangle {
 }
 
 func (img *bitmapImage) At(x, y int) color.Color {
-	i := (uint(y) * img.width) + uint(x) + img.offset
-	byteIndex := i / 8
-	byteShift := i % 8
-	data := img.data
-	if byteIndex < uint(len(data)) {
-		b := data[byteIndex]
-		v := b >> byte(byteShift) & 0b1
-		if v == 1 {
-			return colorOne
-		}
+	return img.AlphaAt(x, y)
+}
+
+func (img *bitmapImage) AlphaAt(x, y int) color.Alpha {
+	if !(image.Point{x, y}.In(img.bounds)) {
+		return colorZero
+	}
+	i := (uint(y) * img.width) + uint(x)
+	return color.Alpha{A: (img.data[i/8] >> (i % 8) & 0b1) * 0xff}
+}
+
+// RGBA64At implements [image.RGBA64Image]: image/draw uses it
+// to read the mask pixels without allocating a color.Color.
+func (img *bitmapImage) RGBA64At(x, y int) color.RGBA64 {
+	a := uint16(img.AlphaAt(x, y).A) * 0x101
+	return color.RGBA64{R: a, G: a, B: a, A: a}
+}
+
+func (img *bitmapImage) Opaque() bool {
+	return false
+}
+
+// alpha returns the expanded version of the atlas.
+// The atlas data should be loaded.
+func (img *bitmapImage) alpha() *image.Alpha {
+	img.alphaOnce.Do(func() {
+		img.alphaMask = img.toAlpha()
+	})
+	return img.alphaMask
+}
+
+// toAlpha expands the atlas bits into bytes.
+// The result has the same layout, so it can be used with the same maskp.
+func (img *bitmapImage) toAlpha() *image.Alpha {
+	alpha := image.NewAlpha(img.bounds)
+	for i := range alpha.Pix {
+		alpha.Pix[i] = (img.data[i/8] >> (i % 8) & 0b1) * 0xff
 	}
-	return colorZero
+	return alpha
 }
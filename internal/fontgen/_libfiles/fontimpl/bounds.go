@@ -0,0 +1,34 @@
+package fontimpl
+
+import (
+	"fmt"
+
+	"golang.org/x/image/font"
+)
+
+// CellBounds returns a font that reports the entire glyph cell
+// rectangle from its GlyphBounds method instead of the glyph ink bounds.
+//
+// This is useful for the layouts that need the bounds
+// to be identical for all glyphs (like grids or text fields).
+//
+// This function will only work with fonts created by
+// this package. Any other font will make it panic.
+func CellBounds(f font.Face) font.Face {
+	switch f := f.(type) {
+	case *bitmapFont:
+		return &scaledFont{
+			font:       f,
+			scale:      1,
+			cellBounds: true,
+		}
+	case *scaledFont:
+		withCellBounds := *f
+		withCellBounds.cellBounds = true
+		return &withCellBounds
+	case *anchoredFont:
+		return WithMarkAnchors(CellBounds(f.face))
+	default:
+		panic(fmt.Sprintf("expected a bitmap font, got %T", f))
+	}
+}
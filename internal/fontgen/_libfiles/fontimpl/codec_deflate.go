@@ -0,0 +1,19 @@
+package fontimpl
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"strings"
+)
+
+func inflate(data string) []byte {
+	r := flate.NewReader(strings.NewReader(data))
+
+	var uncompressed bytes.Buffer
+	if _, err := uncompressed.ReadFrom(r); err != nil {
+		panic(fmt.Errorf("uncompress: %v", err))
+	}
+
+	return uncompressed.Bytes()
+}
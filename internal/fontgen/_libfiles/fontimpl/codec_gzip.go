@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"compress/gzip"
 	"fmt"
+	"strings"
 )
 
-func uncompress(data []byte) []byte {
-	gzr, err := gzip.NewReader(bytes.NewReader(data))
+func gunzip(data string) []byte {
+	gzr, err := gzip.NewReader(strings.NewReader(data))
 	if err != nil {
 		panic(fmt.Errorf("uncompress: %v", err))
 	}
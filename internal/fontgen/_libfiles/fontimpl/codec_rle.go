@@ -0,0 +1,26 @@
+package fontimpl
+
+// decodeRLE decodes the data produced by the generator RLE codec.
+// The data is a sequence of packets that start with a header byte h:
+//
+//   - h < 0x80: a literal packet, the next h+1 bytes are copied as is
+//   - h >= 0x80: a run packet, the next byte is repeated h-0x80+2 times
+func decodeRLE(data string) []byte {
+	var decoded []byte
+	for i := 0; i < len(data); {
+		h := int(data[i])
+		i++
+		if h < 0x80 {
+			n := h + 1
+			decoded = append(decoded, data[i:i+n]...)
+			i += n
+			continue
+		}
+		b := data[i]
+		i++
+		for n := h - 0x80 + 2; n > 0; n-- {
+			decoded = append(decoded, b)
+		}
+	}
+	return decoded
+}
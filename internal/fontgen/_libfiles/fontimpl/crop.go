@@ -0,0 +1,47 @@
+package fontimpl
+
+// The glyph bitmaps can be stored cropped to their ink rectangles.
+// The cropped data of n glyphs starts with n rectangle headers
+// (minX, minY, width and height varints in the glyph cell coordinates),
+// followed by the bits of every glyph rectangle, row by row.
+// Unlike the atlas, the rectangles bits are not separated by any gaps.
+//
+// The atlas layout is restored right after the decompression,
+// so the cropping only affects the stored data size.
+
+// decodeBitmap decompresses the atlas data of numGlyphs glyphs.
+func decodeBitmap(data string, w, h, numGlyphs int) []byte {
+	bits := uncompress(data)
+	if isCropped(data) {
+		bits = uncropBitmap(bits, w, h, numGlyphs)
+	}
+	return bits
+}
+
+// uncropBitmap restores the atlas of w×h glyph cells from the cropped data.
+func uncropBitmap(data []byte, w, h, numGlyphs int) []byte {
+	type rect struct {
+		x, y, w, h int
+	}
+	r := tableReader{data: data}
+	rects := make([]rect, numGlyphs)
+	for i := range rects {
+		rects[i] = rect{x: int(r.uint()), y: int(r.uint()), w: int(r.uint()), h: int(r.uint())}
+	}
+	bits := r.data
+
+	atlas := make([]byte, (numGlyphs*w*h+7)/8)
+	src := 0
+	for i, rc := range rects {
+		cell := i * w * h
+		for y := rc.y; y < rc.y+rc.h; y++ {
+			dst := cell + y*w + rc.x
+			for x := 0; x < rc.w; x++ {
+				atlas[dst/8] |= (bits[src/8] >> (src % 8) & 0b1) << (dst % 8)
+				src++
+				dst++
+			}
+		}
+	}
+	return atlas
+}
@@ -0,0 +1,264 @@
+package fontimpl
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// DrawString draws s using the c color; x and y specify the dot position
+// (the baseline origin), just like the [font.Drawer] Dot field.
+//
+// Unlike the [font.Drawer], it reads the glyph bits directly
+// without going through the masks and image/draw.
+// The *image.RGBA, *image.NRGBA, *image.Paletted and *image.Alpha
+// destinations have specialized implementations, other images
+// are drawn pixel by pixel using their Set method.
+// The *image.Paletted destination uses the c closest palette color,
+// the color alpha is ignored there.
+//
+// The combining marks with anchors are attached to their base glyphs
+// and stacked over each other, while the [font.Drawer] places them
+// over the default base unless the font is created by [WithMarkAnchors].
+// Use [BoundString] to measure the result.
+//
+// This function will only work with fonts created by
+// this package. Any other font will make it panic.
+func DrawString(f font.Face, dst draw.Image, x, y int, s string, c color.Color) {
+	sr, sg, sb, sa := c.RGBA()
+	if sa == 0 {
+		return
+	}
+	paletteIndex := uint8(0)
+	if p, ok := dst.(*image.Paletted); ok {
+		paletteIndex = uint8(p.Palette.Index(c))
+	}
+
+	clip := dst.Bounds()
+	layoutString(f, x, y, s, func(g glyphRef, dr image.Rectangle, scale int) {
+		b := glyphBlit{
+			r:      dr.Intersect(clip),
+			origin: dr.Min,
+			scale:  scale,
+		}
+		if b.r.Empty() {
+			return
+		}
+		img, origin := g.seg.glyphImage(g.index)
+		b.img = img
+		b.maskY = origin.Y
+		switch dst := dst.(type) {
+		case *image.RGBA:
+			b.drawRGBA(dst, sr, sg, sb, sa)
+		case *image.NRGBA:
+			b.drawNRGBA(dst, sr, sg, sb, sa)
+		case *image.Paletted:
+			b.drawPaletted(dst, paletteIndex)
+		case *image.Alpha:
+			b.drawAlpha(dst, sa)
+		default:
+			b.draw(dst, c, sr, sg, sb, sa)
+		}
+	})
+}
+
+// BoundString returns the bounds of s drawn by [DrawString]
+// at the origin and the s advance width.
+//
+// It's like [font.BoundString], but it takes the combining marks
+// placement of DrawString into account.
+//
+// This function will only work with fonts created by
+// this package. Any other font will make it panic.
+func BoundString(f font.Face, s string) (bounds fixed.Rectangle26_6, advance fixed.Int26_6) {
+	var ink image.Rectangle
+	advance = layoutString(f, 0, 0, s, func(g glyphRef, dr image.Rectangle, scale int) {
+		m := g.metrics()
+		if m.minX == m.maxX {
+			return
+		}
+		r := image.Rect(int(m.minX), int(m.minY), int(m.maxX), int(m.maxY))
+		ink = ink.Union(image.Rectangle{Min: r.Min.Mul(scale), Max: r.Max.Mul(scale)}.Add(dr.Min))
+	})
+	bounds = fixed.Rectangle26_6{
+		Min: fixed.P(ink.Min.X, ink.Min.Y),
+		Max: fixed.P(ink.Max.X, ink.Max.Y),
+	}
+	return bounds, advance
+}
+
+// layoutString calls fn for every s glyph that is going to be drawn,
+// dr is the glyph cell destination rectangle.
+// It returns the s advance width.
+func layoutString(f font.Face, x, y int, s string, fn func(g glyphRef, dr image.Rectangle, scale int)) fixed.Int26_6 {
+	var face interface {
+		glyph(dot fixed.Point26_6, r rune) (glyphRef, image.Rectangle, fixed.Int26_6, bool)
+		Kern(r0, r1 rune) fixed.Int26_6
+	}
+	var bf *bitmapFont
+	scale := 1
+	switch f := unwrapAnchored(f).(type) {
+	case *bitmapFont:
+		face = f
+		bf = f
+	case *scaledFont:
+		face = f
+		bf = f.font
+		scale = f.scale
+	default:
+		panic(fmt.Sprintf("expected a bitmap font, got %T", f))
+	}
+
+	var marks markPlacement
+	dot := fixed.P(x, y)
+	prev := rune(-1)
+	for _, r := range s {
+		r0 := prev
+		prev = r
+		if r0 >= 0 {
+			dot.X += face.Kern(r0, r)
+		}
+		g, dr, advance, ok := face.glyph(dot, r)
+		if !ok {
+			continue
+		}
+		dot.X += advance
+		if bf.hasAnchors && r0 >= 0 && isMark(r) {
+			// Glyph places the mark over the default base,
+			// move it to the actual base glyph anchors.
+			offset := bf.placeMark(&marks, r0, r, g).Sub(bf.defaultMarkOffset(r))
+			dr = dr.Add(offset.Mul(scale))
+		}
+		fn(g, dr, scale)
+	}
+	return dot.X - fixed.I(x)
+}
+
+// glyphBlit maps the destination pixels to the glyph atlas bits.
+type glyphBlit struct {
+	r      image.Rectangle // The clipped destination rectangle
+	origin image.Point     // The unclipped destination rectangle min point
+	scale  int
+	img    *bitmapImage
+	maskY  int // The glyph atlas row
+}
+
+func (b *glyphBlit) opaque(x, y int) bool {
+	mx := x - b.origin.X
+	my := y - b.origin.Y
+	if b.scale != 1 {
+		mx /= b.scale
+		my /= b.scale
+	}
+	i := uint(b.maskY+my)*b.img.width + uint(mx)
+	return b.img.data[i/8]>>(i%8)&0b1 != 0
+}
+
+// The blending below follows the image/draw Over operator formulas.
+const maxAlpha = 0xffff
+
+func (b *glyphBlit) drawRGBA(dst *image.RGBA, sr, sg, sb, sa uint32) {
+	a := (maxAlpha - sa) * 0x101
+	for y := b.r.Min.Y; y < b.r.Max.Y; y++ {
+		i := dst.PixOffset(b.r.Min.X, y)
+		for x := b.r.Min.X; x < b.r.Max.X; x, i = x+1, i+4 {
+			if !b.opaque(x, y) {
+				continue
+			}
+			d := dst.Pix[i : i+4 : i+4]
+			if sa == maxAlpha {
+				d[0] = uint8(sr >> 8)
+				d[1] = uint8(sg >> 8)
+				d[2] = uint8(sb >> 8)
+				d[3] = uint8(sa >> 8)
+				continue
+			}
+			d[0] = uint8((uint32(d[0])*a/maxAlpha + sr) >> 8)
+			d[1] = uint8((uint32(d[1])*a/maxAlpha + sg) >> 8)
+			d[2] = uint8((uint32(d[2])*a/maxAlpha + sb) >> 8)
+			d[3] = uint8((uint32(d[3])*a/maxAlpha + sa) >> 8)
+		}
+	}
+}
+
+func (b *glyphBlit) drawNRGBA(dst *image.NRGBA, sr, sg, sb, sa uint32) {
+	a := maxAlpha - sa
+	for y := b.r.Min.Y; y < b.r.Max.Y; y++ {
+		i := dst.PixOffset(b.r.Min.X, y)
+		for x := b.r.Min.X; x < b.r.Max.X; x, i = x+1, i+4 {
+			if !b.opaque(x, y) {
+				continue
+			}
+			d := dst.Pix[i : i+4 : i+4]
+			if sa == maxAlpha {
+				d[0] = uint8(sr >> 8)
+				d[1] = uint8(sg >> 8)
+				d[2] = uint8(sb >> 8)
+				d[3] = 0xff
+				continue
+			}
+			// Blend in the premultiplied space and convert the result back.
+			da := uint32(d[3]) * 0x101
+			outA := sa + da*a/maxAlpha
+			if outA == 0 {
+				continue
+			}
+			outR := sr + uint32(d[0])*0x101*da/maxAlpha*a/maxAlpha
+			outG := sg + uint32(d[1])*0x101*da/maxAlpha*a/maxAlpha
+			outB := sb + uint32(d[2])*0x101*da/maxAlpha*a/maxAlpha
+			d[0] = uint8((outR * maxAlpha / outA) >> 8)
+			d[1] = uint8((outG * maxAlpha / outA) >> 8)
+			d[2] = uint8((outB * maxAlpha / outA) >> 8)
+			d[3] = uint8(outA >> 8)
+		}
+	}
+}
+
+func (b *glyphBlit) drawPaletted(dst *image.Paletted, index uint8) {
+	for y := b.r.Min.Y; y < b.r.Max.Y; y++ {
+		i := dst.PixOffset(b.r.Min.X, y)
+		for x := b.r.Min.X; x < b.r.Max.X; x, i = x+1, i+1 {
+			if b.opaque(x, y) {
+				dst.Pix[i] = index
+			}
+		}
+	}
+}
+
+func (b *glyphBlit) drawAlpha(dst *image.Alpha, sa uint32) {
+	a := maxAlpha - sa
+	for y := b.r.Min.Y; y < b.r.Max.Y; y++ {
+		i := dst.PixOffset(b.r.Min.X, y)
+		for x := b.r.Min.X; x < b.r.Max.X; x, i = x+1, i+1 {
+			if b.opaque(x, y) {
+				dst.Pix[i] = uint8((sa + uint32(dst.Pix[i])*0x101*a/maxAlpha) >> 8)
+			}
+		}
+	}
+}
+
+func (b *glyphBlit) draw(dst draw.Image, c color.Color, sr, sg, sb, sa uint32) {
+	a := maxAlpha - sa
+	for y := b.r.Min.Y; y < b.r.Max.Y; y++ {
+		for x := b.r.Min.X; x < b.r.Max.X; x++ {
+			if !b.opaque(x, y) {
+				continue
+			}
+			if sa == maxAlpha {
+				dst.Set(x, y, c)
+				continue
+			}
+			dr, dg, db, da := dst.At(x, y).RGBA()
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(sr + dr*a/maxAlpha),
+				G: uint16(sg + dg*a/maxAlpha),
+				B: uint16(sb + db*a/maxAlpha),
+				A: uint16(sa + da*a/maxAlpha),
+			})
+		}
+	}
+}
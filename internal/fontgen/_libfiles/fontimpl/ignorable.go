@@ -0,0 +1,41 @@
+package fontimpl
+
+import (
+	"unicode"
+)
+
+// isIgnorable reports whether r is a control character
+// or a default-ignorable code point.
+//
+// These runes should not be visible and should not affect
+// the text layout unless the font defines them explicitly.
+func isIgnorable(r rune) bool {
+	return unicode.Is(unicode.Cc, r) || unicode.Is(defaultIgnorable, r)
+}
+
+// defaultIgnorable is a Default_Ignorable_Code_Point property table.
+// See https://www.unicode.org/reports/tr44/#Default_Ignorable_Code_Point
+var defaultIgnorable = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00ad, Hi: 0x00ad, Stride: 1}, // Soft hyphen
+		{Lo: 0x034f, Hi: 0x034f, Stride: 1}, // Combining grapheme joiner
+		{Lo: 0x061c, Hi: 0x061c, Stride: 1}, // Arabic letter mark
+		{Lo: 0x115f, Hi: 0x1160, Stride: 1}, // Hangul fillers
+		{Lo: 0x17b4, Hi: 0x17b5, Stride: 1}, // Khmer inherent vowels
+		{Lo: 0x180b, Hi: 0x180f, Stride: 1}, // Mongolian variation selectors
+		{Lo: 0x200b, Hi: 0x200f, Stride: 1}, // ZWSP, ZWNJ, ZWJ, LRM, RLM
+		{Lo: 0x202a, Hi: 0x202e, Stride: 1}, // Bidi embedding controls
+		{Lo: 0x2060, Hi: 0x206f, Stride: 1}, // Word joiner, invisible operators, bidi isolates
+		{Lo: 0x3164, Hi: 0x3164, Stride: 1}, // Hangul filler
+		{Lo: 0xfe00, Hi: 0xfe0f, Stride: 1}, // Variation selectors
+		{Lo: 0xfeff, Hi: 0xfeff, Stride: 1}, // Zero width no-break space (BOM)
+		{Lo: 0xffa0, Hi: 0xffa0, Stride: 1}, // Halfwidth Hangul filler
+		{Lo: 0xfff0, Hi: 0xfff8, Stride: 1}, // Unassigned specials
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1bca0, Hi: 0x1bca3, Stride: 1}, // Shorthand format controls
+		{Lo: 0x1d173, Hi: 0x1d17a, Stride: 1}, // Musical symbol format controls
+		{Lo: 0xe0000, Hi: 0xe0fff, Stride: 1}, // Tags and variation selectors supplement
+	},
+	LatinOffset: 1,
+}
@@ -0,0 +1,111 @@
+package fontimpl
+
+import (
+	"sync/atomic"
+)
+
+// The rune mapping resolves the segment runes into their data indices.
+//
+// There are several mapping strategies; the generator selects one of them
+// for the entire package, so the runeMapping type is an alias to the
+// selected implementation and the lookups are never dispatched dynamically.
+//
+// The caller is responsible for the [MinRune, MaxRune] range check.
+
+// rangeMapping is a sorted table of rune ranges.
+// Every range is a run of consecutive runes that have consecutive data indices,
+// so the contiguous blocks (like the alphabets) take a single entry.
+type rangeMapping struct {
+	ranges []runeRange
+
+	// lastRange is the last matched range index used by the lookup heuristic.
+	lastRange atomic.Uint32
+}
+
+type runeRange struct {
+	start rune
+	n     uint32 // The number of runes in the range
+	index uint32 // The first rune data index
+}
+
+func (m *rangeMapping) lookup(r rune) (uint, bool) {
+	ranges := m.ranges
+
+	// Most of the time we're looking for a rune from the
+	// same language, so it's likely to be in the same range
+	// as the previous one.
+	// The index is read and written atomically,
+	// since the mapping is shared by all fonts of the same size.
+	if last := uint(m.lastRange.Load()); last < uint(len(ranges)) {
+		rr := ranges[last]
+		if offset := uint32(r - rr.start); offset < rr.n {
+			return uint(rr.index + offset), true
+		}
+	}
+
+	// This is an inlined sort.Search specialized for our slice:
+	// find the first range that starts after r.
+	i, j := 0, len(ranges)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if ranges[h].start <= r {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	if i == 0 {
+		return 0, false
+	}
+
+	rr := ranges[i-1]
+	if offset := uint32(r - rr.start); offset < rr.n {
+		// Save the results for the heuristic above.
+		m.lastRange.Store(uint32(i - 1))
+		return uint(rr.index + offset), true
+	}
+	return 0, false
+}
+
+// lutMapping is a dense lookup table that is used for the small fonts.
+// The table[r-min] is the rune data index plus one; zero means that the rune is missing.
+type lutMapping struct {
+	min   rune
+	table []dataIndex
+}
+
+func (m *lutMapping) lookup(r rune) (uint, bool) {
+	if i := uint(r - m.min); i < uint(len(m.table)) {
+		if v := m.table[i]; v != 0 {
+			return uint(v - 1), true
+		}
+	}
+	return 0, false
+}
+
+// mappingPageBits is a base-2 logarithm of the pageMapping page size.
+// The generator uses the same value.
+const mappingPageBits = 8
+
+// pageMapping is a two-level lookup table that is used for the big fonts.
+//
+// The runes are split into the pages of 1<<mappingPageBits runes.
+// The pages[p] is the page p offset inside the entries (measured in pages);
+// the entries have the same format as the lutMapping table.
+// All empty pages share the first entries page which is filled with zeros.
+type pageMapping struct {
+	min     rune
+	pages   []dataIndex
+	entries []dataIndex
+}
+
+func (m *pageMapping) lookup(r rune) (uint, bool) {
+	i := uint(r - m.min)
+	if p := i >> mappingPageBits; p < uint(len(m.pages)) {
+		offset := uint(m.pages[p])<<mappingPageBits | i&(1<<mappingPageBits-1)
+		if v := m.entries[offset]; v != 0 {
+			return uint(v - 1), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package fontimpl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Option configures a font allocated by the New constructors.
+type Option func(*options)
+
+type options struct {
+	// tags is nil when all tags are included.
+	tags map[string]bool
+
+	alphaMasks bool
+}
+
+// WithTags limits the font glyphs to the given tags.
+//
+// The glyphs of other tags are never decompressed,
+// so they don't take any memory; these runes are handled
+// like any other missing rune.
+//
+// Virtual tags (like "@whitespace" or "@compose") are always included.
+//
+// The font allocation panics if some of the tags are not defined in the font
+// or if the font is generated without the tag segments (see the --tag-segments flag).
+func WithTags(tags ...string) Option {
+	return func(o *options) {
+		if o.tags == nil {
+			o.tags = make(map[string]bool, len(tags))
+		}
+		for _, tag := range tags {
+			o.tags[tag] = true
+		}
+	}
+}
+
+// WithAlphaMasks makes the font use the [image.Alpha] glyph masks.
+//
+// The image/draw package has a fast path for these masks, so drawing
+// the text with [golang.org/x/image/font.Drawer] becomes several times faster.
+// The downside is memory usage: every glyph pixel takes
+// a byte instead of a bit. Like the bitmap data, these masks
+// are created on the first use and shared by all fonts of the same size.
+//
+// The scaled fonts (see [Scale]) don't use these masks.
+func WithAlphaMasks() Option {
+	return func(o *options) {
+		o.alphaMasks = true
+	}
+}
+
+func (o *options) includesTag(tag string) bool {
+	if o.tags == nil || strings.HasPrefix(tag, "@") {
+		return true
+	}
+	return o.tags[tag]
+}
+
+// tagSegment is a tag segment that is added to the font
+// only if its tag is included.
+type tagSegment struct {
+	tag string
+	seg *fontSegment
+}
+
+// load adds the tag segments and applies the options.
+// It's called by the New constructors after the core segment is added.
+//
+// The tags are all font tags, including the ones that are excluded by the build tags;
+// it's nil if the font is generated without the tag segments.
+func (f *bitmapFont) load(tags []string, segments []tagSegment, opts []Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.tags != nil && tags == nil {
+		panic("WithTags: the font is generated without the tag segments")
+	}
+	for tag := range o.tags {
+		if !slices.Contains(tags, tag) {
+			panic(fmt.Sprintf("WithTags: the font has no %q tag", tag))
+		}
+	}
+	for _, s := range segments {
+		if o.includesTag(s.tag) {
+			f.addSegment(s.seg)
+		}
+	}
+	f.alphaMasks = o.alphaMasks
+}
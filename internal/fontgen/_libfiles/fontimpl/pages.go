@@ -0,0 +1,86 @@
+package fontimpl
+
+import (
+	"image"
+	"sync"
+	"sync/atomic"
+)
+
+// bitmapPages is a paged glyphs atlas used for the very large segments.
+//
+// Every page is an independently compressed atlas of pageSize glyphs
+// (the last page can be shorter), so only the pages that are
+// actually used get decompressed.
+//
+// With a positive cacheSize, at most cacheSize pages are kept decompressed;
+// the oldest page is dropped when a new one is loaded.
+// The masks that were returned before that remain valid.
+type bitmapPages struct {
+	compressed string
+	offsets    []uint32 // The page i data is compressed[offsets[i]:offsets[i+1]]
+
+	width     int // The glyph cell width
+	height    int // The glyph cell height
+	numGlyphs int
+	pageSize  uint
+	cacheSize int
+
+	pages []atomic.Pointer[bitmapImage]
+
+	// mu serializes the page loading and the cache updates.
+	mu sync.Mutex
+	// loaded are the decompressed page indices, oldest first.
+	// It's only used when the cache is bounded.
+	loaded []uint
+}
+
+func newBitmapPages(compressed string, offsets []uint32, w, h, numGlyphs, pageSize, cacheSize int) *bitmapPages {
+	return &bitmapPages{
+		compressed: compressed,
+		offsets:    offsets,
+		width:      w,
+		height:     h,
+		numGlyphs:  numGlyphs,
+		pageSize:   uint(pageSize),
+		cacheSize:  cacheSize,
+		pages:      make([]atomic.Pointer[bitmapImage], len(offsets)-1),
+	}
+}
+
+// glyphImage returns the page atlas that holds the glyph with
+// the given data index and the glyph origin inside that page.
+func (p *bitmapPages) glyphImage(index uint) (*bitmapImage, image.Point) {
+	page := p.page(index / p.pageSize)
+	return page, page.glyphOrigin(index % p.pageSize)
+}
+
+func (p *bitmapPages) page(i uint) *bitmapImage {
+	if img := p.pages[i].Load(); img != nil {
+		return img
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Some other goroutine could load it while we were waiting.
+	if img := p.pages[i].Load(); img != nil {
+		return img
+	}
+
+	numGlyphs := min(int(p.pageSize), p.numGlyphs-int(i*p.pageSize))
+	img := &bitmapImage{
+		data:   decodeBitmap(p.compressed[p.offsets[i]:p.offsets[i+1]], p.width, p.height, numGlyphs),
+		width:  uint(p.width),
+		height: uint(p.height),
+		bounds: image.Rect(0, 0, p.width, p.height*numGlyphs),
+	}
+	if p.cacheSize > 0 {
+		if len(p.loaded) == p.cacheSize {
+			p.pages[p.loaded[0]].Store(nil)
+			p.loaded = append(p.loaded[:0], p.loaded[1:]...)
+		}
+		p.loaded = append(p.loaded, i)
+	}
+	p.pages[i].Store(img)
+	return img
+}
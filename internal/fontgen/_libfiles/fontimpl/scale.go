@@ -15,6 +15,9 @@ import (
 // A scaling factor of 1 is a no-op.
 // A scaling factor of 2 makes the pixels twice as big.
 //
+// Scaling an already scaled font multiplies the factors.
+// The spacing set by [WithSpacing] is scaled as well.
+//
 // This function will only work with fonts created by
 // this package. Any other font will make it panic.
 func Scale(f font.Face, scaling uint) font.Face {
@@ -25,46 +28,116 @@ func Scale(f font.Face, scaling uint) font.Face {
 		return f
 	}
 
-	bf, ok := f.(*bitmapFont)
-	if !ok {
+	switch f := f.(type) {
+	case *bitmapFont:
+		return &scaledFont{
+			font:  f,
+			scale: int(scaling),
+			masks: newScaledMasks(f, int(scaling)),
+		}
+	case *scaledFont:
+		scaled := *f
+		scaled.scale *= int(scaling)
+		scaled.tracking *= int(scaling)
+		scaled.lineGap *= int(scaling)
+		scaled.masks = newScaledMasks(f.font, scaled.scale)
+		return &scaled
+	case *anchoredFont:
+		return WithMarkAnchors(Scale(f.face, scaling))
+	default:
 		panic(fmt.Sprintf("expected a bitmap font, got %T", f))
 	}
+}
 
-	return &scaledFont{
-		font:  bf,
-		scale: int(scaling),
+// scaledFont is a bitmapFont view that has its pixels scaled
+// and its glyph advances and line height adjusted.
+//
+// The tracking and lineGap values are specified in the
+// result pixels, they're already multiplied by the scale.
+type scaledFont struct {
+	font       *bitmapFont
+	scale      int // A positive value, 1 or higher
+	tracking   int
+	lineGap    int
+	cellBounds bool
+
+	// masks are the scaled segment atlases, indexed like the font segments.
+	// They're allocated once, so Glyph doesn't allocate.
+	// It's nil when scale is 1.
+	//
+	// The paged segments have nil masks, their pages are scaled
+	// on demand instead (see [bitmapImage.scaled]).
+	masks []*scaledImage
+}
+
+func newScaledMasks(f *bitmapFont, scale int) []*scaledImage {
+	masks := make([]*scaledImage, len(f.segments))
+	for i, seg := range f.segments {
+		if seg.img != nil {
+			masks[i] = newScaledImage(seg.img, scale)
+		}
 	}
+	return masks
 }
 
-type scaledFont struct {
-	font  *bitmapFont
-	scale int // A positive value, 2 or higher
+func newScaledImage(img *bitmapImage, scale int) *scaledImage {
+	return &scaledImage{
+		img:   img,
+		scale: scale,
+		bounds: image.Rectangle{
+			Min: img.bounds.Min.Mul(scale),
+			Max: img.bounds.Max.Mul(scale),
+		},
+	}
+}
+
+// scaled returns the scaled version of the atlas.
+// The last result is cached, so it only allocates
+// when the atlas is used with several scales.
+func (img *bitmapImage) scaled(scale int) *scaledImage {
+	if m := img.scaledMask.Load(); m != nil && m.scale == scale {
+		return m
+	}
+	m := newScaledImage(img, scale)
+	img.scaledMask.Store(m)
+	return m
 }
 
 func (sf *scaledFont) Close() error {
 	return sf.font.Close()
 }
 
-func (s *scaledFont) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, _ image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
-	var bmask *bitmapImage
-	dr, bmask, advance, ok = s.font.glyph(dot, r)
+func (s *scaledFont) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	g, dr, advance, ok := s.glyph(dot, r)
 	if !ok {
-		return dr, bmask, maskp, advance, false
+		return dr, nil, maskp, advance, false
 	}
 
-	d := image.Pt(dot.X.Floor(), dot.Y.Floor())
-	dr.Min = dr.Min.Sub(d).Mul(s.scale).Add(d)
-	dr.Max = dr.Max.Sub(d).Mul(s.scale).Add(d)
-	advance *= fixed.Int26_6(s.scale)
-	scaledMask := &scaledImage{
-		img:   bmask,
-		scale: s.scale,
-		bounds: image.Rectangle{
-			Min: bmask.bounds.Min.Mul(s.scale * 2),
-			Max: bmask.bounds.Max.Mul(s.scale * 2),
-		},
+	if s.scale == 1 {
+		img, origin := g.seg.glyphImage(g.index)
+		return dr, s.font.mask(img), origin, advance, true
 	}
-	return dr, scaledMask, maskp, advance, true
+	img, origin := g.seg.glyphImage(g.index)
+	scaled := s.masks[g.segIndex]
+	if scaled == nil {
+		scaled = img.scaled(s.scale)
+	}
+	return dr, scaled, origin.Mul(s.scale), advance, true
+}
+
+func (s *scaledFont) glyph(dot fixed.Point26_6, r rune) (g glyphRef, dr image.Rectangle, advance fixed.Int26_6, ok bool) {
+	g, dr, advance, ok = s.font.glyph(dot, r)
+	if !ok {
+		return g, dr, advance, false
+	}
+
+	advance = s.scaleAdvance(advance)
+	if s.scale != 1 {
+		d := image.Pt(dot.X.Floor(), dot.Y.Floor())
+		dr.Min = dr.Min.Sub(d).Mul(s.scale).Add(d)
+		dr.Max = dr.Max.Sub(d).Mul(s.scale).Add(d)
+	}
+	return g, dr, advance, true
 }
 
 func (s *scaledFont) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
@@ -72,12 +145,15 @@ func (s *scaledFont) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
 	if !ok {
 		return 0, false
 	}
-	advance *= fixed.Int26_6(s.scale)
-	return advance, true
+	return s.scaleAdvance(advance), true
 }
 
 func (s *scaledFont) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
-	bounds, advance, ok = s.font.GlyphBounds(r)
+	if s.cellBounds {
+		bounds, advance, ok = s.font.cellGlyphBounds(r)
+	} else {
+		bounds, advance, ok = s.font.GlyphBounds(r)
+	}
 	if !ok {
 		return bounds, advance, false
 	}
@@ -85,23 +161,39 @@ func (s *scaledFont) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fi
 	bounds.Min.Y *= fixed.Int26_6(s.scale)
 	bounds.Max.X *= fixed.Int26_6(s.scale)
 	bounds.Max.Y *= fixed.Int26_6(s.scale)
-	advance *= fixed.Int26_6(s.scale)
+	advance = s.scaleAdvance(advance)
 	return bounds, advance, true
 }
 
 func (s *scaledFont) Kern(r0, r1 rune) fixed.Int26_6 {
-	return s.font.Kern(r0, r1) * fixed.Int26_6(s.scale)
+	kern := s.font.Kern(r0, r1) * fixed.Int26_6(s.scale)
+	if kern != 0 && isMark(r1) {
+		// Marks are drawn over the previous glyph,
+		// the tracking should not move them away from it.
+		kern -= fixed.I(s.tracking)
+	}
+	return kern
 }
 
 func (s *scaledFont) Metrics() font.Metrics {
 	m := s.font.Metrics()
 	return font.Metrics{
-		Height:  m.Height * fixed.Int26_6(s.scale),
-		Ascent:  m.Ascent * fixed.Int26_6(s.scale),
-		Descent: m.Descent * fixed.Int26_6(s.scale),
+		Height:    m.Height*fixed.Int26_6(s.scale) + fixed.I(s.lineGap),
+		Ascent:    m.Ascent * fixed.Int26_6(s.scale),
+		Descent:   m.Descent * fixed.Int26_6(s.scale),
+		XHeight:   m.XHeight * fixed.Int26_6(s.scale),
+		CapHeight: m.CapHeight * fixed.Int26_6(s.scale),
 	}
 }
 
+func (s *scaledFont) scaleAdvance(advance fixed.Int26_6) fixed.Int26_6 {
+	if advance == 0 {
+		// Zero-width glyphs are not affected by tracking.
+		return 0
+	}
+	return advance*fixed.Int26_6(s.scale) + fixed.I(s.tracking)
+}
+
 func euclidianDiv(x, y int) int {
 	if x < 0 {
 		x -= y - 1
@@ -124,7 +216,28 @@ func (s *scaledImage) Bounds() image.Rectangle {
 }
 
 func (s *scaledImage) At(x, y int) color.Color {
+	return s.AlphaAt(x, y)
+}
+
+func (s *scaledImage) AlphaAt(x, y int) color.Alpha {
 	x = euclidianDiv(x, s.scale)
 	y = euclidianDiv(y, s.scale)
-	return s.img.At(x, y)
+	return s.img.AlphaAt(x, y)
+}
+
+// RGBA64At implements [image.RGBA64Image], see [bitmapImage.RGBA64At].
+// The coordinates inside the bounds are never negative,
+// so they're divided without the euclidianDiv rounding.
+func (s *scaledImage) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(s.bounds)) {
+		return color.RGBA64{}
+	}
+	scale := uint(s.scale)
+	i := (uint(y)/scale)*s.img.width + uint(x)/scale
+	a := uint16((s.img.data[i/8]>>(i%8))&0b1) * 0xffff
+	return color.RGBA64{R: a, G: a, B: a, A: a}
+}
+
+func (s *scaledImage) Opaque() bool {
+	return false
 }
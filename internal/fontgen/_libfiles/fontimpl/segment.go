@@ -0,0 +1,100 @@
+package fontimpl
+
+import (
+	"image"
+	"sync"
+)
+
+// fontSegment is a self-contained part of the font glyphs:
+// it has its own bitmap, rune mapping, metrics and anchors.
+//
+// Every font has a core segment that holds the stub and zero-width glyphs.
+// All other glyphs are stored in the tag segments: they can be excluded
+// during the font allocation (see [WithTags]) or compiled out
+// using their build tags.
+//
+// The generated segments are package-level values that are shared
+// by all fonts of the same size, so they must be safe for concurrent use.
+type fontSegment struct {
+	// Either img or pages is set.
+	// The pages are used for the very large segments.
+	img   *bitmapImage
+	pages *bitmapPages
+
+	// tables is the encoded RuneMapping, GlyphMetrics and Anchors data.
+	// It's only set when they're not stored as Go literals, see loadTables.
+	tables     string
+	tablesOnce sync.Once
+
+	MinRune      rune
+	MaxRune      rune
+	RuneMapping  runeMapping
+	GlyphMetrics []glyphMetrics
+	Anchors      []glyphAnchors
+}
+
+// glyphRef is a glyph location: a segment and a data index inside it.
+// segIndex is the segment position inside the font segments slice.
+//
+// The glyph bits location is resolved separately
+// for the glyphs that are going to be drawn, see [fontSegment.glyphImage].
+type glyphRef struct {
+	seg      *fontSegment
+	segIndex int
+	index    uint
+}
+
+func (g glyphRef) metrics() glyphMetrics {
+	return g.seg.GlyphMetrics[g.index]
+}
+
+// cellSize returns the glyph cell width and height.
+func (s *fontSegment) cellSize() (w, h int) {
+	if s.pages != nil {
+		return s.pages.width, s.pages.height
+	}
+	return int(s.img.width), int(s.img.height)
+}
+
+// glyphImage returns the loaded atlas that holds the glyph with
+// the given data index and the glyph origin inside that atlas.
+//
+// The glyph bits are only needed to draw it,
+// so the metrics-only calls never use this method.
+func (s *fontSegment) glyphImage(index uint) (*bitmapImage, image.Point) {
+	if s.pages != nil {
+		return s.pages.glyphImage(index)
+	}
+	img := s.img
+	img.load()
+	return img, img.glyphOrigin(index)
+}
+
+func (s *fontSegment) getRuneDataIndex(r rune) (uint, bool) {
+	// First do a quick range check.
+	if r > s.MaxRune || r < s.MinRune {
+		return 0, false
+	}
+
+	return s.RuneMapping.lookup(r)
+}
+
+func (s *fontSegment) findAnchors(r rune) *glyphAnchors {
+	slice := s.Anchors
+
+	// This is an inlined sort.Search specialized for our slice.
+	i, j := 0, len(slice)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if slice[h].r < r {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+
+	if i < len(slice) && slice[i].r == r {
+		return &slice[i]
+	}
+	return nil
+}
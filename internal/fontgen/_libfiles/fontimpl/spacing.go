@@ -0,0 +1,46 @@
+package fontimpl
+
+import (
+	"fmt"
+
+	"golang.org/x/image/font"
+)
+
+// WithSpacing returns a font that has extra space between
+// the glyphs (tracking) and between the lines (lineGap).
+//
+// Both values are specified in pixels of the given font;
+// they can be negative to make the text more dense.
+// Tracking is added to every glyph advance,
+// lineGap is added to the Metrics().Height.
+//
+// The spacing is accumulated: applying WithSpacing to a font
+// that already has some spacing adds the new values to the old ones.
+// [Scale] multiplies the spacing along with the pixels.
+//
+// This function will only work with fonts created by
+// this package. Any other font will make it panic.
+func WithSpacing(f font.Face, tracking, lineGap int) font.Face {
+	if tracking == 0 && lineGap == 0 {
+		return f
+	}
+
+	switch f := f.(type) {
+	case *bitmapFont:
+		return &scaledFont{
+			font:     f,
+			scale:    1,
+			tracking: tracking,
+			lineGap:  lineGap,
+		}
+	case *scaledFont:
+		spaced := *f
+		spaced.tracking += tracking
+		spaced.lineGap += lineGap
+		return &spaced
+	case *anchoredFont:
+		return WithMarkAnchors(WithSpacing(f.face, tracking, lineGap))
+	default:
+		panic(fmt.Sprintf("expected a bitmap font, got %T", f))
+	}
+}
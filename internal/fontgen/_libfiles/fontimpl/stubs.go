@@ -5,14 +5,36 @@ package fontimpl
 // Instead, the generator would inject the appropriate values on its own.
 
 const (
-	onMissing = "emptymask"
+	onMissing      = "emptymask"
+	croppedBitmaps = false
 )
 
-type runeAndIndex struct {
-	r rune
-	i uint32
+// missingStrategy is the font missing glyph action;
+// the zero value means the onMissing constant.
+type missingStrategy string
+
+func (s missingStrategy) get() string {
+	if s == "" {
+		return onMissing
+	}
+	return string(s)
+}
+
+func uncompress(data string) []byte {
+	return gunzip(data)
+}
+
+func isCropped(data string) bool {
+	return croppedBitmaps
 }
 
-func getStubImageIndex(fontID int) uint {
-	return 0
+type dataIndex = uint16
+
+type runeMapping = rangeMapping
+
+type metricsValue = uint8
+
+type glyphMetrics struct {
+	minX, minY, maxX, maxY metricsValue
+	advance                metricsValue
 }
@@ -0,0 +1,142 @@
+package fontimpl
+
+import (
+	"encoding/binary"
+)
+
+// The segment tables (the rune mapping, glyph metrics and anchors)
+// can be stored as an encoded binary blob instead of the Go literals.
+// This keeps the generated Go code small for the big fonts.
+//
+// The blob is decoded when the segment is added to a font for the first time,
+// so the lookups never check whether the tables are loaded.
+//
+// All numbers are varints (see encoding/binary), the blob layout is:
+//
+//	mapping  (see the runeMapping decode methods)
+//	metrics  count, then minX, minY, maxX, maxY, advance for every glyph
+//	anchors  count, then the rune delta, flags and the flagged points for every entry
+//
+// The mapping layout depends on the strategy:
+//
+//	rangeMapping  count, then the start delta, n and index for every range
+//	lutMapping    min, count, then the table values
+//	pageMapping   min, count, the pages values, count, the entries values
+//
+// The range start delta is relative to the previous range end.
+// The anchor points are encoded as two signed bytes.
+
+// loadTables decodes the segment tables if they're stored as binary data.
+func (s *fontSegment) loadTables() {
+	s.tablesOnce.Do(s.decodeTables)
+}
+
+func (s *fontSegment) decodeTables() {
+	if s.tables == "" {
+		return
+	}
+
+	r := tableReader{data: uncompress(s.tables)}
+
+	s.RuneMapping.decode(&r)
+
+	s.GlyphMetrics = make([]glyphMetrics, r.uint())
+	for i := range s.GlyphMetrics {
+		s.GlyphMetrics[i] = glyphMetrics{
+			minX:    metricsValue(r.uint()),
+			minY:    metricsValue(r.uint()),
+			maxX:    metricsValue(r.uint()),
+			maxY:    metricsValue(r.uint()),
+			advance: metricsValue(r.uint()),
+		}
+	}
+
+	if n := r.uint(); n != 0 {
+		s.Anchors = make([]glyphAnchors, n)
+	}
+	prevRune := rune(0)
+	for i := range s.Anchors {
+		a := &s.Anchors[i]
+		a.r = prevRune + rune(r.uint())
+		a.flags = r.byte()
+		if a.flags&anchorTop != 0 {
+			a.top = r.point()
+		}
+		if a.flags&anchorBottom != 0 {
+			a.bottom = r.point()
+		}
+		if a.flags&(anchorMarkTop|anchorMarkBottom) != 0 {
+			a.mark = r.point()
+		}
+		prevRune = a.r
+	}
+}
+
+func (m *rangeMapping) decode(r *tableReader) {
+	m.ranges = make([]runeRange, r.uint())
+	end := rune(0)
+	for i := range m.ranges {
+		rr := &m.ranges[i]
+		rr.start = end + rune(r.uint())
+		rr.n = uint32(r.uint())
+		rr.index = uint32(r.uint())
+		end = rr.start + rune(rr.n)
+	}
+}
+
+func (m *lutMapping) decode(r *tableReader) {
+	m.min = rune(r.int())
+	m.table = r.indices()
+}
+
+func (m *pageMapping) decode(r *tableReader) {
+	m.min = rune(r.int())
+	m.pages = r.indices()
+	m.entries = r.indices()
+}
+
+// tableReader decodes the segment tables blob.
+// The blob is created by the generator, so it's never malformed
+// unless the package files were modified.
+type tableReader struct {
+	data []byte
+}
+
+func (r *tableReader) uint() uint {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		panic("decode tables: malformed data")
+	}
+	r.data = r.data[n:]
+	return uint(v)
+}
+
+func (r *tableReader) int() int {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		panic("decode tables: malformed data")
+	}
+	r.data = r.data[n:]
+	return int(v)
+}
+
+func (r *tableReader) byte() byte {
+	if len(r.data) == 0 {
+		panic("decode tables: malformed data")
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *tableReader) point() [2]int8 {
+	return [2]int8{int8(r.byte()), int8(r.byte())}
+}
+
+func (r *tableReader) indices() []dataIndex {
+	indices := make([]dataIndex, r.uint())
+	for i := range indices {
+		indices[i] = dataIndex(r.uint())
+	}
+	return indices
+}
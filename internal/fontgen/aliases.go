@@ -0,0 +1,79 @@
+package fontgen
+
+import (
+	"fmt"
+	"slices"
+	"unicode"
+)
+
+// resolveAliases adds the alias entries described by the aliases file.
+//
+// An alias re-uses its target glyph, it doesn't have its own image.
+// The runes that are defined in the font are never replaced by aliases.
+func (g *generator) resolveAliases() error {
+	size1index := g.font.Size1.runeIndexMap()
+
+	targets := make(map[rune]rune, len(g.font.Aliases))
+	for _, a := range g.font.Aliases {
+		targets[a.Value] = a.Target
+	}
+
+	var aliases []runeAlias
+	for _, a := range g.font.Aliases {
+		if i, ok := size1index[a.Value]; ok {
+			g.warnings = append(g.warnings, fmt.Sprintf("%s: rune is defined in the font, ignoring its alias", g.font.Size1.Runes[i]))
+			continue
+		}
+		// An alias can point to another alias, the chain
+		// is followed until the rune that is defined in the font.
+		target := a.Target
+		for n := 0; ; n++ {
+			if _, ok := size1index[target]; ok {
+				break
+			}
+			next, ok := targets[target]
+			if !ok {
+				return fmt.Errorf("%v(%q): alias target %v(%q) is not defined in the font", a.Value, a.Value, target, target)
+			}
+			if n == len(targets) {
+				return fmt.Errorf("%v(%q): aliases chain has a cycle", a.Value, a.Value)
+			}
+			target = next
+		}
+		aliases = append(aliases, runeAlias{Value: a.Value, Target: target})
+	}
+
+	if g.font.CaseFold {
+		// Letters are visited in a stable order, so the first
+		// defined case variant becomes the alias target.
+		letters := make([]rune, 0, len(g.font.Size1.Runes))
+		for _, r := range g.font.Size1.Runes {
+			letters = append(letters, r.Value)
+		}
+		slices.Sort(letters)
+		folded := make(map[rune]bool)
+		for _, a := range aliases {
+			folded[a.Value] = true
+		}
+		for _, target := range letters {
+			for r := unicode.SimpleFold(target); r != target; r = unicode.SimpleFold(r) {
+				if _, ok := size1index[r]; ok || folded[r] {
+					continue
+				}
+				folded[r] = true
+				aliases = append(aliases, runeAlias{Value: r, Target: target})
+			}
+		}
+	}
+
+	for _, sf := range g.font.Sized {
+		index := sf.runeIndexMap()
+		for _, a := range aliases {
+			br := sf.Runes[index[a.Target]].aliasAs(a.Value)
+			sf.Runes = append(sf.Runes, br)
+			g.config.DebugPrint(fmt.Sprintf("%s: alias of %v(%q)", br, a.Target, a.Target))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package fontgen
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newAliasesTestDataDir(t *testing.T, aliasesJSON string) string {
+	glyphs := newTestGlyphs()
+	glyphs['o'] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"#...#",
+		".###.",
+		".....",
+	}
+	glyphs['B'] = testGlyph{
+		".....",
+		".....",
+		"###..",
+		"#..#.",
+		"###..",
+		"#..#.",
+		"###..",
+		".....",
+	}
+	dir := writeTestDataDir(t, testTags{"latin": glyphs})
+	if err := os.WriteFile(filepath.Join(dir, aliasesFilename), []byte(aliasesJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		aliases map[rune]rune
+		warns   int
+	}{
+		{
+			name:    "simple",
+			json:    `{"aliases": {"0": "o", "ß": "B"}}`,
+			aliases: map[rune]rune{'0': 'o', 'ß': 'B'},
+		},
+		{
+			name:    "rune codes",
+			json:    `{"aliases": {"48": "o", "0xdf": "66"}}`,
+			aliases: map[rune]rune{'0': 'o', 'ß': 'B'},
+		},
+		{
+			name:    "chain",
+			json:    `{"aliases": {"ő": "ö", "ö": "o", "Ő": "Ö", "Ö": "ő"}}`,
+			aliases: map[rune]rune{'ő': 'o', 'ö': 'o', 'Ő': 'o', 'Ö': 'o'},
+		},
+		{
+			name:    "defined rune",
+			json:    `{"aliases": {"x": "o", "0": "o"}}`,
+			aliases: map[rune]rune{'0': 'o'},
+			warns:   1,
+		},
+		{
+			name:    "case fold",
+			json:    `{"caseFold": true}`,
+			aliases: map[rune]rune{'O': 'o', 'X': 'x', 'b': 'B'},
+		},
+		{
+			name: "case fold with aliases",
+			json: `{"caseFold": true, "aliases": {"O": "0", "0": "B"}}`,
+			// The explicit O alias is not overridden by the case folding.
+			aliases: map[rune]rune{'O': 'B', '0': 'B', 'X': 'x', 'b': 'B'},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, result := generateTestFont(t, Config{
+				DataDir: newAliasesTestDataDir(t, test.json),
+			})
+
+			aliases := make(map[rune]rune, len(result.FontInfo.Aliases))
+			for _, a := range result.FontInfo.Aliases {
+				aliases[a.Value] = a.Target
+				if a.StringValue != string(a.Value) || a.TargetStringValue != string(a.Target) {
+					t.Fatalf("%q: unexpected string values: %q -> %q", a.Value, a.StringValue, a.TargetStringValue)
+				}
+			}
+			if !maps.Equal(aliases, test.aliases) {
+				t.Fatalf("aliases:\nhave: %q\nwant: %q", aliases, test.aliases)
+			}
+			for _, info := range result.FontInfo.Runes {
+				if _, ok := aliases[info.Value]; ok {
+					t.Fatalf("%q: alias is listed as a font rune", info.Value)
+				}
+			}
+			for r, target := range test.aliases {
+				br := g.size1Rune(t, r)
+				if !br.IsAlias || br.ImgIndex != g.font.Size1.findRune(target) {
+					t.Fatalf("%q: doesn't re-use the %q image", r, target)
+				}
+			}
+			if len(result.Warnings) != test.warns {
+				t.Fatalf("have %d warnings, want %d: %q", len(result.Warnings), test.warns, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestAliasesError(t *testing.T) {
+	tests := []struct {
+		json string
+		err  string
+	}{
+		{`{"aliases": {"0": "O"}}`, `alias target 79('O') is not defined in the font`},
+		{`{"aliases": {"ő": "ö", "ö": "Ö"}}`, `alias target 214('Ö') is not defined in the font`},
+		{`{"aliases": {"ő": "ö", "ö": "ő"}}`, `aliases chain has a cycle`},
+		{`{"aliases": {"o": "o"}}`, `rune can't be an alias of itself`},
+		{`{"aliases": {"0": "ab"}}`, `parse "ab" as rune`},
+		{`{"aliases": {"111": "x", "o": "x"}}`, `duplicated alias`},
+	}
+
+	for _, test := range tests {
+		g := newGenerator(Config{
+			DataDir:       newAliasesTestDataDir(t, test.json),
+			ResultPackage: "testfont",
+			OutDir:        filepath.Join(t.TempDir(), "testfont"),
+		})
+		_, err := g.Generate()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%s:\nhave error: %v\nwant error: %s", test.json, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package fontgen
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"strings"
+)
+
+// BitmapCodec is a glyph bitmap data encoding.
+type BitmapCodec int
+
+const (
+	GzipCodec BitmapCodec = iota
+	NoCodec
+	DeflateCodec
+	RLECodec
+
+	numCodecs
+)
+
+func (c BitmapCodec) String() string {
+	switch c {
+	case GzipCodec:
+		return "gzip"
+	case NoCodec:
+		return "none"
+	case DeflateCodec:
+		return "deflate"
+	case RLECodec:
+		return "rle"
+	default:
+		return "?"
+	}
+}
+
+// decodeFunc returns the runtime function that decodes the data.
+// It's empty for NoCodec.
+func (c BitmapCodec) decodeFunc() string {
+	switch c {
+	case GzipCodec:
+		return "gunzip"
+	case DeflateCodec:
+		return "inflate"
+	case RLECodec:
+		return "decodeRLE"
+	default:
+		return ""
+	}
+}
+
+// libFile returns the runtime file that implements the decodeFunc.
+func (c BitmapCodec) libFile() string {
+	if c == NoCodec {
+		return ""
+	}
+	return "codec_" + c.String() + ".go"
+}
+
+// fileExt returns the bitmap data file extension.
+func (c BitmapCodec) fileExt() string {
+	switch c {
+	case GzipCodec:
+		return ".gz"
+	case DeflateCodec:
+		return ".deflate"
+	case RLECodec:
+		return ".rle"
+	default:
+		return ""
+	}
+}
+
+func (c BitmapCodec) encode(data []byte) ([]byte, error) {
+	switch c {
+	case NoCodec:
+		return data, nil
+	case GzipCodec:
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return compressed.Bytes(), nil
+	case DeflateCodec:
+		var compressed bytes.Buffer
+		w, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return compressed.Bytes(), nil
+	case RLECodec:
+		return encodeRLE(data), nil
+	default:
+		return nil, fmt.Errorf("unexpected codec: %v", c)
+	}
+}
+
+// croppedDataFlag marks the cropped glyph bitmaps in the shared runtime data format byte.
+// It should be identical to the fontrt croppedFlag value.
+const croppedDataFlag = 0x80
+
+// encodeData encodes the generated package data.
+// The shared runtime selects the codec using the first data byte,
+// so it's added to the encoded data in that mode.
+// For the cropped glyph bitmaps, this byte also has the croppedDataFlag bit set.
+func (g *generator) encodeData(codec BitmapCodec, data []byte, cropped bool) ([]byte, error) {
+	encoded, err := codec.encode(data)
+	if err != nil || !g.config.SharedRuntime {
+		return encoded, err
+	}
+	format := byte(codec)
+	if cropped {
+		format |= croppedDataFlag
+	}
+	return append([]byte{format}, encoded...), nil
+}
+
+// encodeRLE is a simple run-length encoding that is decoded by the decodeRLE
+// runtime function. The data is a sequence of packets that start with a header byte h:
+//
+//   - h < 0x80: a literal packet, the next h+1 bytes are copied as is
+//   - h >= 0x80: a run packet, the next byte is repeated h-0x80+2 times
+//
+// The glyph bitmaps are mostly made of the zero bytes,
+// so this encoding is a cheap alternative to the compress packages.
+func encodeRLE(data []byte) []byte {
+	const (
+		maxLiteral = 0x80
+		maxRun     = 0x7f + 2
+	)
+	var encoded []byte
+	literalStart := 0
+	flushLiteral := func(end int) {
+		for literalStart < end {
+			n := min(end-literalStart, maxLiteral)
+			encoded = append(encoded, byte(n-1))
+			encoded = append(encoded, data[literalStart:literalStart+n]...)
+			literalStart += n
+		}
+	}
+	for i := 0; i < len(data); {
+		runLength := 1
+		for i+runLength < len(data) && data[i+runLength] == data[i] && runLength < maxRun {
+			runLength++
+		}
+		if runLength < 3 {
+			// Short runs are cheaper to encode as literals.
+			i += runLength
+			continue
+		}
+		flushLiteral(i)
+		encoded = append(encoded, byte(0x80+runLength-2), data[i])
+		i += runLength
+		literalStart = i
+	}
+	flushLiteral(len(data))
+	return encoded
+}
+
+// quoteData formats the data as a Go string literal.
+// Unlike strconv.Quote, it only keeps the printable ASCII characters,
+// so the result is stable and easy to diff.
+func quoteData(data []byte) string {
+	const hexDigits = "0123456789abcdef"
+	var sb strings.Builder
+	sb.Grow(len(data)*4 + 2)
+	sb.WriteByte('"')
+	for _, b := range data {
+		switch {
+		case b == '"' || b == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case b >= 0x20 && b < 0x7f:
+			sb.WriteByte(b)
+		default:
+			sb.WriteString(`\x`)
+			sb.WriteByte(hexDigits[b>>4])
+			sb.WriteByte(hexDigits[b&0xf])
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
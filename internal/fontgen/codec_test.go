@@ -0,0 +1,91 @@
+package fontgen
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/quasilyte/bitfontier/fontrt"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// newRuntimeFont creates a shared runtime font from the segment;
+// the bitmap holds its glyph cells bits, like the generator atlas.
+// The tables are encoded without compression.
+func newRuntimeFont(t *testing.T, seg *fontSegment, strategy MappingStrategy, codec BitmapCodec, bitmap []byte) font.Face {
+	t.Helper()
+
+	g := newGenerator(Config{SharedRuntime: true})
+	seg.mapping.build(strategy)
+	numGlyphs := len(seg.GlyphMetrics)
+	data, err := g.encodeData(codec, cropData(bitmap, seg.GlyphWidth, seg.GlyphHeight, 0, numGlyphs), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := g.encodeData(NoCodec, encodeTables(seg, strategy, true), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	core := fontrt.NewSegment(string(data), string(tables), seg.GlyphWidth, seg.GlyphHeight, numGlyphs, seg.MinRune, seg.MaxRune)
+	config := fontrt.FontConfig{
+		Core:           core,
+		DotY:           seg.GlyphHeight,
+		OnMissing:      "emptymask",
+		ZeroWidthIndex: -1,
+	}
+	return fontrt.NewFont(config, nil, nil)
+}
+
+// checkRuntimeGlyph compares the glyph mask with its cell bits.
+func checkRuntimeGlyph(t *testing.T, face font.Face, r rune, bitmap []byte, index, w, h int) {
+	t.Helper()
+
+	_, mask, maskp, _, ok := face.Glyph(fixed.Point26_6{}, r)
+	if !ok {
+		t.Fatalf("%q: glyph is not found", r)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := index*w*h + y*w + x
+			want := bitmap[i/8]>>(i%8)&0b1 != 0
+			_, _, _, a := mask.At(maskp.X+x, maskp.Y+y).RGBA()
+			if have := a != 0; have != want {
+				t.Fatalf("%q: pixel at %d,%d: have %v, want %v", r, x, y, have, want)
+			}
+		}
+	}
+}
+
+func TestRLERoundTrip(t *testing.T) {
+	// The 64x64 glyphs take 512 bytes: 'a' is fully inked, so it's a long run,
+	// 'b' is noise, so it's a long literal (its ink covers the whole cell too).
+	const w, h = 64, 64
+	const glyphSize = w * h / 8
+	bitmap := bytes.Repeat([]byte{0xff}, glyphSize)
+	noise := make([]byte, glyphSize)
+	rand.New(rand.NewSource(1)).Read(noise)
+	noise[0], noise[glyphSize-1] = 0xff, 0xff
+	bitmap = append(bitmap, noise...)
+
+	encoded := encodeRLE(bitmap)
+	if len(encoded) >= len(bitmap) {
+		t.Fatalf("encoded %d bytes into %d bytes", len(bitmap), len(encoded))
+	}
+
+	seg := &fontSegment{
+		GlyphWidth:  w,
+		GlyphHeight: h,
+		MinRune:     'a',
+		MaxRune:     'b',
+		GlyphMetrics: []glyphMetrics{
+			{InkBounds: image.Rect(0, 0, w, h), Advance: w},
+			{InkBounds: image.Rect(0, 0, w, h), Advance: w},
+		},
+		mapping: newRuneMapping([]runeAndIndex{{'a', 0}, {'b', 1}}),
+	}
+	face := newRuntimeFont(t, seg, RangeMapping, RLECodec, bitmap)
+	checkRuntimeGlyph(t, face, 'a', bitmap, 0, w, h)
+	checkRuntimeGlyph(t, face, 'b', bitmap, 1, w, h)
+}
@@ -0,0 +1,209 @@
+package fontgen
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// synthesizeComposites builds the missing precomposed runes (like é or Ё)
+// by overlaying the glyphs from their canonical decomposition.
+func (g *generator) synthesizeComposites() error {
+	if len(g.config.ComposeRanges) == 0 {
+		return nil
+	}
+
+	// The size=1 font decides which runes can be synthesized.
+	// Other sizes synthesize the same set of runes;
+	// if some components are missing there, a stub is used instead.
+	var candidates []rune
+	size1index := g.font.Size1.runeIndexMap()
+	for _, rr := range g.config.ComposeRanges {
+		for r := rr.Min; r <= rr.Max; r++ {
+			if i, ok := size1index[r]; ok && !g.font.Size1.Runes[i].IsStub {
+				continue
+			}
+			if _, ok := composeComponents(g.font.Size1, size1index, r); ok {
+				candidates = append(candidates, r)
+			}
+		}
+	}
+	g.config.DebugPrint(fmt.Sprintf("%d runes can be composed", len(candidates)))
+
+	for _, sf := range g.font.Sized {
+		index := sf.runeIndexMap()
+		for _, r := range candidates {
+			if i, ok := index[r]; ok && !sf.Runes[i].IsStub {
+				continue
+			}
+			components, ok := composeComponents(sf, index, r)
+			if !ok {
+				br := bitmapRune{
+					Value:       r,
+					IsStub:      true,
+					Tag:         composeTag,
+					Size:        sf.Size,
+					Advance:     sf.GlyphWidth,
+					ImgIndex:    -1,
+					Synthesized: true,
+				}
+				sf.setRune(br)
+				g.warnings = append(g.warnings, fmt.Sprintf("%s: some components are missing, using a placeholder image", br))
+				continue
+			}
+			img, clipped := g.composeGlyph(sf, components[0], components[1:])
+			br := bitmapRune{
+				Value:       r,
+				Img:         img,
+				Tag:         composeTag,
+				Size:        sf.Size,
+				Advance:     components[0].Advance,
+				ImgIndex:    -1,
+				Synthesized: true,
+			}
+			if clipped {
+				g.warnings = append(g.warnings, fmt.Sprintf("%s: some marks are clipped by the glyph bounds", br))
+			}
+			sf.setRune(br)
+			g.config.DebugPrint(fmt.Sprintf("%s: composed from %+q", br, norm.NFD.String(string(r))))
+		}
+	}
+
+	return nil
+}
+
+// composeComponents returns the glyphs for r canonical decomposition.
+// The first returned rune is a base, the rest are the combining marks.
+func composeComponents(sf *sizedBitmapFont, index map[rune]int, r rune) ([]bitmapRune, bool) {
+	decomposed := []rune(norm.NFD.String(string(r)))
+	if len(decomposed) < 2 || isCombiningMark(decomposed[0]) {
+		return nil, false
+	}
+	components := make([]bitmapRune, 0, len(decomposed))
+	for _, c := range decomposed {
+		i, ok := index[c]
+		if !ok || sf.Runes[i].IsStub {
+			return nil, false
+		}
+		components = append(components, sf.Runes[i])
+	}
+	return components, true
+}
+
+func (g *generator) composeGlyph(sf *sizedBitmapFont, base bitmapRune, marks []bitmapRune) (*image.NRGBA, bool) {
+	cell := image.Rectangle{Max: image.Pt(sf.GlyphWidth, sf.GlyphHeight)}
+	dst := image.NewNRGBA(cell)
+	draw.Draw(dst, cell, base.Img, base.Img.Bounds().Min, draw.Over)
+
+	// Bases that are taller than x-height get their above marks raised.
+	baseInk := inkBounds(base.Img)
+	tallOffset := 0
+	if sf.LcaseXImage != nil {
+		xTop := inkBounds(sf.LcaseXImage).Min.Y
+		if baseInk.Min.Y < xTop {
+			tallOffset = xTop - baseInk.Min.Y
+			if g.config.ComposeMarkOffset != 0 {
+				scale := float64(sf.GlyphHeight) / float64(g.font.Size1.GlyphHeight)
+				tallOffset = int(math.Round(float64(g.config.ComposeMarkOffset) * scale))
+			}
+		}
+	}
+
+	// The anchor points are used when both base and mark define them.
+	// They're updated after every mark to allow the stacking.
+	var top, bottom *image.Point
+	if base.Anchors != nil {
+		top = base.Anchors.Top
+		bottom = base.Anchors.Bottom
+	}
+
+	// The current composite ink extents, used for the marks stacking
+	// when there are no anchors.
+	inkTop := baseInk.Min.Y
+	inkBottom := baseInk.Max.Y
+	numAbove := 0
+	numBelow := 0
+
+	clipped := false
+	for _, m := range marks {
+		markInk := inkBounds(m.Img)
+		class := combiningMarkClass(m.Value)
+
+		var offset image.Point
+		switch {
+		case class == markAbove && top != nil && m.Anchors != nil && m.Anchors.MarkTop != nil:
+			offset = top.Sub(*m.Anchors.MarkTop)
+			top = nextAnchor(m.Anchors.Top, *top, offset, markInk.Min.Y-1)
+		case class == markBelow && bottom != nil && m.Anchors != nil && m.Anchors.MarkBottom != nil:
+			offset = bottom.Sub(*m.Anchors.MarkBottom)
+			bottom = nextAnchor(m.Anchors.Bottom, *bottom, offset, markInk.Max.Y)
+		case class == markAbove:
+			if numAbove == 0 {
+				offset.Y = -tallOffset
+			} else {
+				// Leave a 1 pixel gap between the stacked marks.
+				offset.Y = (inkTop - 1) - markInk.Max.Y
+			}
+		case class == markBelow:
+			if numBelow != 0 {
+				offset.Y = (inkBottom + 1) - markInk.Min.Y
+			}
+		}
+
+		switch class {
+		case markAbove:
+			numAbove++
+		case markBelow:
+			numBelow++
+		}
+
+		placed := markInk.Add(offset)
+		if !placed.In(cell) {
+			clipped = true
+		}
+		inkTop = min(inkTop, placed.Min.Y)
+		inkBottom = max(inkBottom, placed.Max.Y)
+		draw.Draw(dst, cell.Add(offset), m.Img, m.Img.Bounds().Min, draw.Over)
+	}
+
+	return dst, clipped
+}
+
+// nextAnchor returns the attachment point for the next stacked mark.
+// If the mark doesn't have its own anchor, the point is moved
+// to the mark ink edge (inkY) while keeping the current X.
+func nextAnchor(markAnchor *image.Point, current, offset image.Point, inkY int) *image.Point {
+	if markAnchor != nil {
+		pt := markAnchor.Add(offset)
+		return &pt
+	}
+	return &image.Point{X: current.X, Y: inkY + offset.Y}
+}
+
+type markClass int
+
+const (
+	markAbove markClass = iota
+	markBelow
+	markOverlay
+)
+
+// combiningMarkClass uses the canonical combining class
+// to decide where the mark should be placed.
+func combiningMarkClass(r rune) markClass {
+	switch norm.NFD.PropertiesString(string(r)).CCC() {
+	case 1: // Overlay
+		return markOverlay
+	case 202, 218, 220, 222, 233: // Attached below, below left, below, below right, double below
+		return markBelow
+	default:
+		return markAbove
+	}
+}
+
+func isCombiningMark(r rune) bool {
+	return norm.NFD.PropertiesString(string(r)).CCC() != 0
+}
@@ -0,0 +1,182 @@
+package fontgen
+
+import (
+	"slices"
+	"testing"
+)
+
+func newComposeTestDataDir(t *testing.T) string {
+	glyphs := newTestGlyphs()
+	glyphs['e'] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"##...",
+		".###.",
+		".....",
+	}
+	glyphs['E'] = testGlyph{
+		".....",
+		".....",
+		"####.",
+		"#....",
+		"###..",
+		"#....",
+		"####.",
+		".....",
+	}
+	glyphs['\u0301'] = testGlyph{
+		".....",
+		".....",
+		"..##.",
+		".....",
+		".....",
+		".....",
+		".....",
+		".....",
+	}
+	return writeTestDataDir(t, testTags{"latin": glyphs})
+}
+
+func TestComposeGlyphs(t *testing.T) {
+	tests := []struct {
+		name       string
+		markOffset int
+		want       map[rune]testGlyph
+	}{
+		{
+			name: "default",
+			want: map[rune]testGlyph{
+				'é': {
+					".....",
+					".....",
+					"..##.",
+					".....",
+					".###.",
+					"##...",
+					".###.",
+					".....",
+				},
+				// E is 2 pixels taller than x, so is the mark offset.
+				'É': {
+					"..##.",
+					".....",
+					"####.",
+					"#....",
+					"###..",
+					"#....",
+					"####.",
+					".....",
+				},
+			},
+		},
+		{
+			name:       "mark offset",
+			markOffset: 1,
+			want: map[rune]testGlyph{
+				// The offset is only applied to the tall bases.
+				'é': {
+					".....",
+					".....",
+					"..##.",
+					".....",
+					".###.",
+					"##...",
+					".###.",
+					".....",
+				},
+				'É': {
+					".....",
+					"..##.",
+					"####.",
+					"#....",
+					"###..",
+					"#....",
+					"####.",
+					".....",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, result := generateTestFont(t, Config{
+				DataDir:           newComposeTestDataDir(t),
+				ComposeRanges:     []RuneRange{{Min: 0xC0, Max: 0xFF}},
+				ComposeMarkOffset: test.markOffset,
+			})
+
+			// Other runes from the range have some components missing (like è),
+			// so they're not synthesized.
+			want := []rune{'É', 'é'}
+			if have := runeInfoValues(result.Synthesized); !slices.Equal(have, want) {
+				t.Fatalf("synthesized:\nhave: %q\nwant: %q", have, want)
+			}
+			for _, info := range result.Synthesized {
+				if info.Tag != composeTag {
+					t.Fatalf("%q: have %q tag, want %q", info.Value, info.Tag, composeTag)
+				}
+			}
+
+			if len(result.Warnings) != 0 {
+				t.Fatalf("unexpected warnings: %q", result.Warnings)
+			}
+			for r, want := range test.want {
+				br := g.size1Rune(t, r)
+				if have := glyphRows(br.Img); !slices.Equal(have, want) {
+					t.Fatalf("%q image:\nhave:%v\nwant:%v", r, have, want)
+				}
+			}
+		})
+	}
+}
+
+func TestComposeDefinedRune(t *testing.T) {
+	glyphs := newTestGlyphs()
+	glyphs['e'] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"##...",
+		".###.",
+		".....",
+	}
+	glyphs['\u0301'] = testGlyph{
+		".....",
+		".....",
+		"..##.",
+		".....",
+		".....",
+		".....",
+		".....",
+		".....",
+	}
+	// A hand-drawn glyph always wins over the synthesized one.
+	glyphs['é'] = testGlyph{
+		".....",
+		".....",
+		"...#.",
+		"..#..",
+		".###.",
+		"##...",
+		".###.",
+		".....",
+	}
+	g, result := generateTestFont(t, Config{
+		DataDir:       writeTestDataDir(t, testTags{"latin": glyphs}),
+		ComposeRanges: []RuneRange{{Min: 'é', Max: 'é'}},
+	})
+
+	if len(result.Synthesized) != 0 {
+		t.Fatalf("unexpected synthesized runes: %q", runeInfoValues(result.Synthesized))
+	}
+	br := g.size1Rune(t, 'é')
+	if have, want := glyphRows(br.Img), glyphs['é']; !slices.Equal(have, want) {
+		t.Fatalf("image:\nhave:%v\nwant:%v", have, want)
+	}
+}
@@ -0,0 +1,58 @@
+package fontgen
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// cropData encodes n glyphs starting from the first one cropped
+// to their ink rectangles; the data holds the w×h glyph cells bits.
+// See the runtime crop.go for the format description.
+func cropData(data []byte, w, h, first, n int) []byte {
+	bit := func(glyph, x, y int) byte {
+		i := glyph*w*h + y*w + x
+		return (data[i/8] >> (i % 8)) & 0b1
+	}
+
+	rects := make([]image.Rectangle, n)
+	var cropped []byte
+	for i := range rects {
+		glyph := first + i
+		rect := image.Rectangle{}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if bit(glyph, x, y) == 0 {
+					continue
+				}
+				pixel := image.Rect(x, y, x+1, y+1)
+				if rect.Empty() {
+					rect = pixel
+				} else {
+					rect = rect.Union(pixel)
+				}
+			}
+		}
+		rects[i] = rect
+		cropped = binary.AppendUvarint(cropped, uint64(rect.Min.X))
+		cropped = binary.AppendUvarint(cropped, uint64(rect.Min.Y))
+		cropped = binary.AppendUvarint(cropped, uint64(rect.Dx()))
+		cropped = binary.AppendUvarint(cropped, uint64(rect.Dy()))
+	}
+
+	numBits := 0
+	for _, rect := range rects {
+		numBits += rect.Dx() * rect.Dy()
+	}
+	bits := make([]byte, (numBits+7)/8)
+	bitIndex := 0
+	for i, rect := range rects {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				bits[bitIndex/8] |= bit(first+i, x, y) << (bitIndex % 8)
+				bitIndex++
+			}
+		}
+	}
+
+	return append(cropped, bits...)
+}
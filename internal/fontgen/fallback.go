@@ -0,0 +1,168 @@
+package fontgen
+
+import (
+	"fmt"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// resolveFallbacks adds alias entries for the missing runes
+// that have a close enough glyph in the font.
+//
+// A rune is resolved using its compatibility decomposition (NFKD)
+// with all diacritics stripped: 'ｆ' becomes 'f', 'ﬁ' becomes 'f', 'ǹ' becomes 'n'.
+//
+// When the font is subsetted, only the selected runes are resolved,
+// since all other fallbacks would be removed by the subsetting anyway.
+func (g *generator) resolveFallbacks() error {
+	if g.config.MissingGlyphAction != FallbackOnMissingGlyph {
+		return nil
+	}
+
+	candidates, err := g.fallbackCandidates()
+	if err != nil {
+		return err
+	}
+
+	size1index := g.font.Size1.runeIndexMap()
+	present := func(r rune) bool {
+		_, ok := size1index[r]
+		return ok
+	}
+
+	type fallback struct {
+		r      rune
+		target rune
+	}
+	var fallbacks []fallback
+	for _, r := range candidates {
+		if present(r) {
+			continue
+		}
+		if target, ok := fallbackRune(r, present); ok {
+			fallbacks = append(fallbacks, fallback{r: r, target: target})
+		}
+	}
+	g.config.DebugPrint(fmt.Sprintf("resolved %d missing runes via fallbacks", len(fallbacks)))
+
+	for _, sf := range g.font.Sized {
+		index := sf.runeIndexMap()
+		for _, fb := range fallbacks {
+			br := sf.Runes[index[fb.target]].aliasAs(fb.r)
+			br.IsFallback = true
+			sf.Runes = append(sf.Runes, br)
+		}
+	}
+
+	return nil
+}
+
+// fallbackCandidates returns the sorted list of runes that could have a fallback.
+// These are the runes selected by the SubsetRunes and SubsetFrom
+// or all Unicode runes if the font is not subsetted.
+// The runes without the decomposition (and not listed in strokeLetters)
+// are never included.
+func (g *generator) fallbackCandidates() ([]rune, error) {
+	var buf []byte
+	hasFallback := func(r rune) bool {
+		if _, ok := strokeLetters[r]; ok {
+			return true
+		}
+		buf = utf8.AppendRune(buf[:0], r)
+		return norm.NFKD.Properties(buf).Decomposition() != nil
+	}
+
+	var candidates []rune
+	if !g.hasSubset() {
+		for r := rune(0); r <= unicode.MaxRune; r++ {
+			if hasFallback(r) {
+				candidates = append(candidates, r)
+			}
+		}
+		return candidates, nil
+	}
+
+	corpus, err := g.loadCorpus()
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[rune]struct{}, len(corpus))
+	for r := range corpus {
+		selected[r] = struct{}{}
+	}
+	for _, rr := range g.config.SubsetRunes {
+		for r := rr.Min; r <= rr.Max; r++ {
+			selected[r] = struct{}{}
+		}
+	}
+	for r := range selected {
+		if hasFallback(r) {
+			candidates = append(candidates, r)
+		}
+	}
+	slices.Sort(candidates)
+	return candidates, nil
+}
+
+// fallbackRune finds the closest rune for r that passes the present filter.
+func fallbackRune(r rune, present func(rune) bool) (rune, bool) {
+	decomposed := []rune(norm.NFKD.String(string(r)))
+
+	// Some decompositions map one mark to another,
+	// they can't be stripped as diacritics.
+	if len(decomposed) == 1 && decomposed[0] != r && present(decomposed[0]) {
+		return decomposed[0], true
+	}
+
+	var letters []rune
+	for _, c := range decomposed {
+		if isCombiningMark(c) {
+			continue
+		}
+		if stripped, ok := strokeLetters[c]; ok {
+			c = stripped
+		}
+		letters = append(letters, c)
+	}
+	switch {
+	case len(letters) == 0:
+		return 0, false
+	case len(letters) > 1:
+		// Ligatures like 'ﬁ' or 'ǆ' are replaced by their first letter.
+		// Other sequences like '½' can't be represented by one glyph.
+		for _, c := range letters {
+			if !unicode.IsLetter(c) {
+				return 0, false
+			}
+		}
+	}
+
+	target := letters[0]
+	if target == r || !present(target) {
+		return 0, false
+	}
+	return target, true
+}
+
+// strokeLetters lists the letters that have a diacritic-like stroke,
+// but have no decomposition that could strip it.
+var strokeLetters = map[rune]rune{
+	'ł': 'l',
+	'Ł': 'L',
+	'ø': 'o',
+	'Ø': 'O',
+	'đ': 'd',
+	'Đ': 'D',
+	'ħ': 'h',
+	'Ħ': 'H',
+	'ŧ': 't',
+	'Ŧ': 'T',
+	'ƀ': 'b',
+	'Ƀ': 'B',
+	'ɨ': 'i',
+	'Ɨ': 'I',
+	'ı': 'i',
+}
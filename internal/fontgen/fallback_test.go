@@ -0,0 +1,141 @@
+package fontgen
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFallbackRune(t *testing.T) {
+	present := func(r rune) bool {
+		switch r {
+		case 'o', 'l', 'f', 'n', 'O', '2':
+			return true
+		default:
+			return false
+		}
+	}
+
+	tests := []struct {
+		r      rune
+		target rune
+	}{
+		{'ő', 'o'},
+		{'Ö', 'O'},
+		{'ǹ', 'n'},
+		{'ł', 'l'},
+		{'ø', 'o'},
+		{'ｆ', 'f'},
+		{'ﬁ', 'f'},
+		{'²', '2'},
+
+		// The fallback target is not in the font.
+		{'ž', 0},
+		{'Ł', 0},
+
+		// Can't be represented by one glyph.
+		{'½', 0},
+
+		// No decomposition at all.
+		{'€', 0},
+		{'o', 0},
+	}
+
+	for _, test := range tests {
+		target, ok := fallbackRune(test.r, present)
+		if ok != (test.target != 0) || target != test.target {
+			t.Fatalf("fallbackRune(%q):\nhave: %q (ok=%v)\nwant: %q", test.r, target, ok, test.target)
+		}
+	}
+}
+
+func newFallbackTestGlyphs() map[rune]testGlyph {
+	glyphs := newTestGlyphs()
+	glyphs['o'] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"#...#",
+		".###.",
+		".....",
+	}
+	glyphs['l'] = testGlyph{
+		".....",
+		".....",
+		"..#..",
+		"..#..",
+		"..#..",
+		"..#..",
+		"..#..",
+		".....",
+	}
+	return glyphs
+}
+
+func TestResolveFallbacks(t *testing.T) {
+	g, result := generateTestFont(t, Config{
+		DataDir:            writeTestDataDir(t, testTags{"latin": newFallbackTestGlyphs()}),
+		MissingGlyphAction: FallbackOnMissingGlyph,
+	})
+
+	if len(result.FontInfo.Aliases) != 0 {
+		t.Fatalf("fallbacks are reported as aliases: %v", result.FontInfo.Aliases)
+	}
+	fallbacks := make(map[rune]rune, len(result.FontInfo.Fallbacks))
+	for _, fb := range result.FontInfo.Fallbacks {
+		fallbacks[fb.Value] = fb.Target
+	}
+	tests := map[rune]rune{
+		'ő':          'o',
+		'ł':          'l',
+		'ẋ':          'x',
+		'ｘ':          'x',
+		'\U0001D431': 'x', // Mathematical bold small x
+		'\U0001D428': 'o', // Mathematical bold small o
+	}
+	for r, want := range tests {
+		if have := fallbacks[r]; have != want {
+			t.Fatalf("%q: have %q fallback, want %q", r, have, want)
+		}
+		br := g.size1Rune(t, r)
+		if !br.IsAlias || !br.IsFallback || br.ImgIndex != g.font.Size1.findRune(want) {
+			t.Fatalf("%q: doesn't re-use the %q image", r, want)
+		}
+	}
+	for _, r := range []rune{'ž', 'Ł', 'a'} {
+		if _, ok := fallbacks[r]; ok {
+			t.Fatalf("%q: unexpected fallback", r)
+		}
+	}
+}
+
+func TestResolveFallbacksSubset(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "corpus.txt")
+	if err := os.WriteFile(corpus, []byte("xł\U0001D431"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, result := generateTestFont(t, Config{
+		DataDir:            writeTestDataDir(t, testTags{"latin": newFallbackTestGlyphs()}),
+		MissingGlyphAction: FallbackOnMissingGlyph,
+		SubsetRunes:        []RuneRange{{Min: 'l', Max: 'o'}, {Min: 'ő', Max: 'ő'}},
+		SubsetFrom:         []string{corpus},
+	})
+
+	// Only the selected runes get the fallbacks.
+	var have []AliasInfo
+	for _, fb := range result.FontInfo.Fallbacks {
+		have = append(have, AliasInfo{Value: fb.Value, Target: fb.Target})
+	}
+	want := []AliasInfo{
+		{Value: 'ł', Target: 'l'},
+		{Value: 'ő', Target: 'o'},
+		{Value: '\U0001D431', Target: 'x'},
+	}
+	if !slices.Equal(have, want) {
+		t.Fatalf("fallbacks mismatch:\nhave: %v\nwant: %v", have, want)
+	}
+}
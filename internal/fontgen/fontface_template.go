@@ -1,23 +1,48 @@
 package fontgen
 
 import (
+	"image"
 	"text/template"
 )
 
 type templateData struct {
 	PkgName string
 
-	Fonts        []*sizedBitmapFont
-	RuneMappings []*runeMapping
+	Fonts []*sizedBitmapFont
 
-	CompactRune bool
+	CompactIndex   bool
+	CompactMetrics bool
+
+	MappingType string
+
+	ZeroWidthIgnorables bool
+
+	SplitTags bool
+
+	// Tags are the font tags that can be selected using WithTags,
+	// it's nil if the font is generated without the tag segments.
+	Tags []string
+
+	InlineData     bool
+	DecodeFunc     string
+	CroppedBitmaps bool
 
 	OnMissing string
+
+	SharedRuntime  bool
+	RuntimeVersion int
 }
 
-type runeMapping struct {
-	SizeApprox int
-	Slice      []runeAndIndex
+type tagSegmentTemplateData struct {
+	PkgName    string
+	Tag        string
+	Name       string
+	BuildTag   string
+	InlineData bool
+
+	SharedRuntime bool
+
+	Segments []*fontSegment
 }
 
 type runeAndIndex struct {
@@ -25,79 +50,457 @@ type runeAndIndex struct {
 	Index int
 }
 
-var fontfaceTemplate = template.Must(template.New("fontface").Parse(`// Code generated by fontget, DO NOT EDIT
+type anchorsEntry struct {
+	Rune   rune
+	Flags  string
+	Top    *image.Point
+	Bottom *image.Point
+	Mark   *image.Point
+
+	// flagBits is a numeric Flags value used for the binary tables.
+	flagBits uint8
+}
+
+// segmentTemplate is shared between the fontface.go and the tag segment files.
+var segmentTemplate = template.Must(template.New("segment").Parse(`
+{{- if .InlineData}}
+const {{.Ident}}data = {{.InlineData}}
+{{- else}}
+//go:embed {{.BitmapFilename}}
+var {{.Ident}}data string
+{{- end}}
+
+{{- if .BinaryTables}}
+
+// The rune mapping, glyph metrics and anchors are decoded
+// when the segment is added to a font for the first time.
+{{- if .InlineTables}}
+const {{.Ident}}tables = {{.InlineTables}}
+{{- else}}
+//go:embed {{.TablesFilename}}
+var {{.Ident}}tables string
+{{- end}}
+{{- end}}
+
+// The data is decompressed on the first use;
+// the segment is shared by all fonts of this size.
+{{- if .SharedRuntime}}
+{{- if .PageOffsets}}
+var {{.Ident}}segment = fontrt.NewPagedSegment({{.Ident}}data, {{.Ident}}tables, {{.Ident}}pageOffsets[:], {{.GlyphWidth}}, {{.GlyphHeight}}, {{len .GlyphMetrics}}, {{.PageSize}}, {{.PageCacheSize}}, {{.MinRune}}, {{.MaxRune}})
+{{- else}}
+var {{.Ident}}segment = fontrt.NewSegment({{.Ident}}data, {{.Ident}}tables, {{.GlyphWidth}}, {{.GlyphHeight}}, {{len .GlyphMetrics}}, {{.MinRune}}, {{.MaxRune}})
+{{- end}}
+{{- else}}
+var {{.Ident}}segment = &fontSegment{
+	{{- if .PageOffsets}}
+	pages:        newBitmapPages({{.Ident}}data, {{.Ident}}pageOffsets[:], {{.GlyphWidth}}, {{.GlyphHeight}}, {{len .GlyphMetrics}}, {{.PageSize}}, {{.PageCacheSize}}),
+	{{- else}}
+	img:          newCompressedBitmapImage({{.Ident}}data, {{.GlyphWidth}}, {{.GlyphHeight}}, {{len .GlyphMetrics}}),
+	{{- end}}
+	MinRune:      {{.MinRune}},
+	MaxRune:      {{.MaxRune}},
+	{{- if .BinaryTables}}
+	tables:       {{.Ident}}tables,
+	{{- else}}
+	{{- if eq .MappingStrategy "ranges"}}
+	RuneMapping:  runeMapping{ranges: {{.Ident}}ranges[:]},
+	{{- else if eq .MappingStrategy "lut"}}
+	RuneMapping:  runeMapping{min: {{.MinRune}}, table: {{.Ident}}lut[:]},
+	{{- else}}
+	RuneMapping:  runeMapping{min: {{.MinRune}}, pages: {{.Ident}}mappingPages[:], entries: {{.Ident}}mappingEntries[:]},
+	{{- end}}
+	GlyphMetrics: {{.Ident}}metrics[:],
+	{{- if .Anchors}}
+	Anchors:      {{.Ident}}anchors[:],
+	{{- end}}
+	{{- end}}
+}
+{{- end}}
+
+{{- if .PageOffsets}}
+
+// The compressed pages of {{.PageSize}} glyphs.
+var {{.Ident}}pageOffsets = [...]uint32{
+	{{- range .PageOffsets}}
+	{{.}},
+	{{- end}}
+}
+{{- end}}
+
+{{- if not .BinaryTables}}
+
+{{- if eq .MappingStrategy "ranges"}}
+
+// len={{len .RuneRanges}} sizeApprox={{.MappingSizeApprox}}
+var {{.Ident}}ranges = [...]runeRange{
+	{{- range .RuneRanges}}
+		{start: {{.Start}}, n: {{.N}}, index: {{.Index}} }, // {{printf "%q" .Start}}{{if gt .N 1}}-{{printf "%q" .Last}}{{end}}
+	{{- end}}
+}
+{{- else if eq .MappingStrategy "lut"}}
+
+// The data index plus one for every rune starting from MinRune.
+// len={{.MappingLen}} sizeApprox={{.MappingSizeApprox}}
+var {{.Ident}}lut = [...]dataIndex{
+	{{- range .MappingLUT}}
+	{{.}}
+	{{- end}}
+}
+{{- else}}
+
+// The page table of the {{.MappingLen}} runes starting from MinRune.
+// sizeApprox={{.MappingSizeApprox}}
+var {{.Ident}}mappingPages = [...]dataIndex{
+	{{- range .MappingPages}}
+	{{.}}
+	{{- end}}
+}
+
+var {{.Ident}}mappingEntries = [...]dataIndex{
+	{{- range .MappingEntries}}
+	{{.}}
+	{{- end}}
+}
+{{- end}}
+
+// Glyph ink rectangles and advances, indexed by the data index.
+var {{.Ident}}metrics = [...]glyphMetrics{
+	{{- range .GlyphMetrics}}
+		{ {{.InkBounds.Min.X}}, {{.InkBounds.Min.Y}}, {{.InkBounds.Max.X}}, {{.InkBounds.Max.Y}}, {{.Advance}} },
+	{{- end}}
+}
+
+{{- if .Anchors}}
+
+var {{.Ident}}anchors = [...]glyphAnchors{
+	{{- range .Anchors}}
+		{r: {{.Rune}}, flags: {{.Flags}}
+		{{- with .Top}}, top: [2]int8{ {{.X}}, {{.Y}} }{{end}}
+		{{- with .Bottom}}, bottom: [2]int8{ {{.X}}, {{.Y}} }{{end}}
+		{{- with .Mark}}, mark: [2]int8{ {{.X}}, {{.Y}} }{{end}} }, // {{printf "%q" .Rune}}
+	{{- end}}
+}
+{{- end}}
+{{- end}}
+`))
+
+var fontfaceTemplate = template.Must(template.Must(segmentTemplate.Clone()).New("fontface").Parse(`// Code generated by fontget, DO NOT EDIT
 
 package {{$.PkgName}}
 
 import (
 	"golang.org/x/image/font"
+	{{- if not $.InlineData}}
 	_ "embed"
+	{{- end}}
 )
 
-{{ if $.CompactRune }}
-// runeAndIndex is a compact version for fonts with low max rune bound.
-// Its size is 4 bytes instead of 8.
-type runeAndIndex struct {
-	r uint16
-	i uint16
-}
+{{ if $.CompactIndex }}
+// dataIndex is a compact version for fonts with at most 65535 glyphs.
+type dataIndex = uint16
 {{ else }}
-type runeAndIndex struct {
-	r rune // int32
-	i uint32
-}
+type dataIndex = uint32
+{{ end }}
+
+type runeMapping = {{$.MappingType}}
+
+{{ if $.CompactMetrics }}
+// metricsValue is a compact version for fonts with glyph size under 256.
+type metricsValue = uint8
+{{ else }}
+type metricsValue = uint16
 {{ end }}
 
+type glyphMetrics struct {
+	minX, minY, maxX, maxY metricsValue
+	advance                metricsValue
+}
+
 {{- range $.Fonts}}
 // New{{.ShortSizeTag}} allocates a font of size={{.Size}}.
 //
-// Allocating several instances of the same-sized font is unadvised
-// as they would not share the resources.
-func New{{.ShortSizeTag}}() font.Face {
-	data := uncompress(size{{.SizeTag}}data)
-	img := newBitmapImage(data, {{.GlyphWidth}}, {{.GlyphHeight}})
-	f := newBitmapFont({{.Index}}, img, {{.DotX}}, {{.DotY}})
-	f.MinRune = {{.MinRune}}
-	f.MaxRune = {{.MaxRune}}
+// Use [WithTags] to load only the glyphs of the selected tags.
+//
+// The font is safe for concurrent use by multiple goroutines.
+//
+// Allocating a font is cheap: the glyph bitmaps are decompressed
+// on the first use and shared by all fonts of this size.
+func New{{.ShortSizeTag}}(opts ...Option) font.Face {
+	f := newBitmapFont({{.Index}}, {{.Core.Ident}}segment, {{.DotX}}, {{.DotY}})
 	f.XHeight = {{.XHeight}}
 	f.CapHeight = {{.CapHeight}}
-	f.GlyphBitSize = {{.GlyphBitSize}}
-	f.RuneMapping = size{{.SizeTag}}mapping[:]
+	f.StubIndex = {{.Core.StubDataIndex}}
+	{{- if $.ZeroWidthIgnorables}}
+	f.ZeroWidthIndex = {{.Core.ZeroWidthDataIndex}}
+	{{- end}}
+	f.load(fontTags, size{{.SizeTag}}segments, opts)
 	return f
 }
 {{end}}
 
+const (
+	onMissing      = "{{.OnMissing}}"
+	croppedBitmaps = {{.CroppedBitmaps}}
+)
+
+// missingStrategy is the font missing glyph action;
+// the zero value means the onMissing constant.
+type missingStrategy string
+
+func (s missingStrategy) get() string {
+	if s == "" {
+		return onMissing
+	}
+	return string(s)
+}
+
+func uncompress(data string) []byte {
+	{{- if .DecodeFunc}}
+	return {{.DecodeFunc}}(data)
+	{{- else}}
+	return []byte(data)
+	{{- end}}
+}
+
+func isCropped(data string) bool {
+	return croppedBitmaps
+}
+
+{{- if $.SplitTags}}
+
+// Tag segments are registered by their files,
+// unless they're excluded by the build tags.
 var (
 	{{- range $.Fonts}}
-	//go:embed {{.BitmapFilename}}
-	size{{.SizeTag}}data []byte 
-	{{end}}
+	size{{.SizeTag}}segments []tagSegment
+	{{- end}}
 )
+{{- else}}
+
+var (
+	{{- range $.Fonts}}
+	size{{.SizeTag}}segments = []tagSegment{
+		{{- range .TagSegments}}
+		{tag: {{printf "%q" .Tag}}, seg: {{.Ident}}segment},
+		{{- end}}
+	}
+	{{- end}}
+)
+{{- end}}
+
+{{- if $.Tags}}
+
+// fontTags are the tags that can be selected using WithTags.
+var fontTags = []string{
+	{{- range $.Tags}}
+	{{printf "%q" .}},
+	{{- end}}
+}
+{{- else}}
 
+// fontTags is nil: the font is generated without the tag segments,
+// so WithTags can't be used.
+var fontTags []string
+{{- end}}
+
+{{- range $.Fonts}}
+{{template "segment" .Core}}
+{{- if not $.SplitTags}}
+{{- range .TagSegments}}
+{{template "segment" .}}
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+var tagSegmentTemplate = template.Must(template.Must(segmentTemplate.Clone()).New("tagsegment").Parse(`// Code generated by fontget, DO NOT EDIT
+
+//go:build !{{$.BuildTag}}
+
+// Glyphs of the {{printf "%q" $.Tag}} tag.
+// Use the {{$.BuildTag}} build tag to exclude them.
+
+package {{$.PkgName}}
+
+{{- if $.SharedRuntime}}
+
+import (
+	{{- if not $.InlineData}}
+	_ "embed"
+
+	{{- end}}
+	"github.com/quasilyte/bitfontier/fontrt"
+)
+{{- else if not $.InlineData}}
+
+import (
+	_ "embed"
+)
+{{- end}}
+
+func init() {
+	{{- range $.Segments}}
+	{{- if $.SharedRuntime}}
+	size{{.SizeTag}}segments = append(size{{.SizeTag}}segments, fontrt.TagSegment{Tag: {{printf "%q" .Tag}}, Segment: {{.Ident}}segment})
+	{{- else}}
+	size{{.SizeTag}}segments = append(size{{.SizeTag}}segments, tagSegment{tag: {{printf "%q" .Tag}}, seg: {{.Ident}}segment})
+	{{- end}}
+	{{- end}}
+}
+
+{{- range $.Segments}}
+{{template "segment" .}}
+{{- end}}
+`))
+
+// sharedFontfaceTemplate is the fontface.go version for Config.SharedRuntime:
+// the runtime is imported, so the package only has the data, the constructors
+// and the wrappers of the runtime API.
+var sharedFontfaceTemplate = template.Must(template.Must(segmentTemplate.Clone()).New("sharedfontface").Parse(`// Code generated by fontget, DO NOT EDIT
+
+package {{$.PkgName}}
+
+import (
+	"image/color"
+	"image/draw"
+	{{- if not $.InlineData}}
+	_ "embed"
+	{{- end}}
+
+	"github.com/quasilyte/bitfontier/fontrt"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// This package uses the shared runtime, see [fontrt].
+// It doesn't compile if the fontrt version is not compatible with this code;
+// use the same bitfontier version for the generation and the runtime.
 const (
-	onMissing = "{{.OnMissing}}"
+	_ = fontrt.EnforceVersion({{$.RuntimeVersion}} - fontrt.MinVersion)
+	_ = fontrt.EnforceVersion(fontrt.Version - {{$.RuntimeVersion}})
 )
 
-func getStubImageIndex(id int) uint {
-	switch id {
-	{{- range $.Fonts }}
-	case {{.Index}}: // size={{.SizeTag}}
-		return {{.StubDataIndex}}
-	{{- end }}
-	default:
-		panic("unreachable")
+{{- range $.Fonts}}
+
+// New{{.ShortSizeTag}} allocates a font of size={{.Size}}.
+//
+// Use [WithTags] to load only the glyphs of the selected tags.
+//
+// The font is safe for concurrent use by multiple goroutines.
+//
+// Allocating a font is cheap: the glyph bitmaps are decompressed
+// on the first use and shared by all fonts of this size.
+func New{{.ShortSizeTag}}(opts ...Option) font.Face {
+	config := fontrt.FontConfig{
+		Core:           {{.Core.Ident}}segment,
+		DotX:           {{.DotX}},
+		DotY:           {{.DotY}},
+		XHeight:        {{.XHeight}},
+		CapHeight:      {{.CapHeight}},
+		OnMissing:      "{{$.OnMissing}}",
+		Tags:           fontTags,
+		StubIndex:      {{.Core.StubDataIndex}},
+		{{- if $.ZeroWidthIgnorables}}
+		ZeroWidthIndex: {{.Core.ZeroWidthDataIndex}},
+		{{- else}}
+		ZeroWidthIndex: -1,
+		{{- end}}
 	}
+	return fontrt.NewFont(config, size{{.SizeTag}}segments, opts)
 }
+{{- end}}
+
+// Option configures a font allocated by the New constructors.
+type Option = fontrt.Option
+
+// WithTags limits the font glyphs to the given tags, see [fontrt.WithTags].
+func WithTags(tags ...string) Option {
+	return fontrt.WithTags(tags...)
+}
+
+// WithAlphaMasks makes the font use the [image.Alpha] glyph masks, see [fontrt.WithAlphaMasks].
+func WithAlphaMasks() Option {
+	return fontrt.WithAlphaMasks()
+}
+
+// Scale takes the bitmap font and returns its scaled version, see [fontrt.Scale].
+// It works with the fonts of any package that uses the shared runtime.
+func Scale(f font.Face, scaling uint) font.Face {
+	return fontrt.Scale(f, scaling)
+}
+
+// WithSpacing returns a font that has extra space between
+// the glyphs (tracking) and between the lines (lineGap), see [fontrt.WithSpacing].
+func WithSpacing(f font.Face, tracking, lineGap int) font.Face {
+	return fontrt.WithSpacing(f, tracking, lineGap)
+}
+
+// CellBounds returns a font that reports the entire glyph cell
+// rectangle from its GlyphBounds method, see [fontrt.CellBounds].
+func CellBounds(f font.Face) font.Face {
+	return fontrt.CellBounds(f)
+}
+
+// WithMarkAnchors returns a font that attaches the combining marks
+// to the anchors of their base glyphs, see [fontrt.WithMarkAnchors].
+func WithMarkAnchors(f font.Face) font.Face {
+	return fontrt.WithMarkAnchors(f)
+}
+
+// DrawString draws the text with its baseline origin at (x, y), see [fontrt.DrawString].
+func DrawString(f font.Face, dst draw.Image, x, y int, s string, c color.Color) {
+	fontrt.DrawString(f, dst, x, y, s, c)
+}
+
+// BoundString returns the bounds of s drawn by [DrawString]
+// and the s advance width, see [fontrt.BoundString].
+func BoundString(f font.Face, s string) (fixed.Rectangle26_6, fixed.Int26_6) {
+	return fontrt.BoundString(f, s)
+}
+
+{{- if $.SplitTags}}
+
+// Tag segments are registered by their files,
+// unless they're excluded by the build tags.
+var (
+	{{- range $.Fonts}}
+	size{{.SizeTag}}segments []fontrt.TagSegment
+	{{- end}}
+)
+{{- else}}
 
 var (
 	{{- range $.Fonts}}
-	{{- $m := (index $.RuneMappings .Index)}}
-	// len={{len $m.Slice}} sizeApprox={{$m.SizeApprox}}
-	size{{.SizeTag}}mapping = [...]runeAndIndex{
-		{{- range $m.Slice}}
-			{r: {{.Rune}}, i: {{.Index}} }, // {{printf "%q" .Rune}}
+	size{{.SizeTag}}segments = []fontrt.TagSegment{
+		{{- range .TagSegments}}
+		{Tag: {{printf "%q" .Tag}}, Segment: {{.Ident}}segment},
 		{{- end}}
 	}
-	{{end}}
+	{{- end}}
 )
+{{- end}}
+
+{{- if $.Tags}}
+
+// fontTags are the tags that can be selected using WithTags.
+var fontTags = []string{
+	{{- range $.Tags}}
+	{{printf "%q" .}},
+	{{- end}}
+}
+{{- else}}
+
+// fontTags is nil: the font is generated without the tag segments,
+// so WithTags can't be used.
+var fontTags []string
+{{- end}}
+
+{{- range $.Fonts}}
+{{template "segment" .Core}}
+{{- if not $.SplitTags}}
+{{- range .TagSegments}}
+{{template "segment" .}}
+{{- end}}
+{{- end}}
+{{- end}}
 `))
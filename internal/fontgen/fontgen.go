@@ -2,6 +2,7 @@ package fontgen
 
 import (
 	"embed"
+	"fmt"
 	"time"
 )
 
@@ -15,11 +16,178 @@ type Config struct {
 
 	OutDir string
 
+	// Tags is a list of tags to include into a font.
+	// An empty list includes all tags.
+	//
+	// Unless TagPriority is set, this list order is also
+	// used as a tags priority.
 	Tags []string
 
+	// TagPriority is an ordered list of tags that can override each other runes.
+	// If a rune is defined in several tags, the one that comes later wins.
+	// Without the priority, defining a rune in several tags is an error.
+	TagPriority []string
+
+	// SplitTags puts every tag glyphs into their own set of files
+	// that can be excluded from the build using a build tag.
+	// For example, the "cjk" tag runes are compiled only
+	// if the bitfont_nocjk build tag is not set.
+	// It implies TagSegments.
+	SplitTags bool
+
+	// TagSegments stores every tag glyphs in a separate font segment,
+	// so the font can be allocated with only some of its tags
+	// (see WithTags in the generated package).
+	// Otherwise, all glyphs share a single segment and the identical
+	// images of different tags are stored only once.
+	TagSegments bool
+
+	// PageSize enables the paged bitmap storage for the large fonts (like CJK).
+	// The segment glyphs are compressed in independent pages of PageSize glyphs,
+	// so only the pages that are actually used get decompressed.
+	// The segments that fit into a single page are stored as usual.
+	// A zero value disables the paging.
+	PageSize int
+
+	// Codec is the glyph bitmap data encoding; GzipCodec is used by default.
+	// RLECodec doesn't need any compress package in the generated code
+	// and NoCodec makes the data loading as cheap as possible
+	// at the cost of the binary size.
+	// See GenerationResult.Bitmaps to compare the codecs.
+	Codec BitmapCodec
+
+	// Mapping is the rune mapping strategy that is used for all fonts in the package.
+	// AutoMapping picks the dense LUT when it's small (up to 4KB) or at most
+	// twice as big as the range table; otherwise, the page table is used under
+	// the same size condition; the range table is selected in all other cases.
+	Mapping MappingStrategy
+
+	// CropGlyphs stores every glyph bitmap cropped to its ink rectangle
+	// instead of the full glyph cell, so the small glyphs (like '.' or ',')
+	// take less space. The cells are restored during the data decoding,
+	// so it doesn't affect the rendering results.
+	// See GenerationResult.Bitmaps to check the saved size.
+	CropGlyphs bool
+
+	// BinaryTables stores the rune mappings, glyph metrics and anchors
+	// as the encoded binary data (using the Codec) instead of the Go literals.
+	// They're decoded when the font is allocated for the first time.
+	// It keeps the generated Go code small regardless of the number of runes,
+	// so the packages that import the font build faster.
+	BinaryTables bool
+
+	// SharedRuntime makes the generated package import the fontrt runtime package
+	// from the bitfontier module instead of getting its own copy of the runtime.
+	// The generated package only contains the font data and the constructors,
+	// so several fonts don't duplicate the runtime code and the faces
+	// of different font packages can be used with the same functions (like Scale).
+	//
+	// The fontrt package version should be compatible with the generator version,
+	// otherwise the generated package doesn't compile.
+	//
+	// This mode requires BinaryTables: the shared runtime can't use the Go literal tables,
+	// so Generate returns an error if it's not set.
+	// The Codec and CropGlyphs are stored in the data, so they work as usual.
+	SharedRuntime bool
+
+	// InlineData puts the bitmap data (and the BinaryTables data) into the Go
+	// string constants instead of the files that are loaded using go:embed.
+	InlineData bool
+
+	// PageCacheSize is the max number of decompressed pages per segment;
+	// the oldest pages are dropped when this limit is reached.
+	// A zero value means that the pages are never dropped.
+	PageCacheSize int
+
+	// SubsetRunes and SubsetFrom reduce the font to the selected runes.
+	// A rune is kept if it belongs to any of SubsetRunes ranges
+	// or if it's used in any of SubsetFrom files.
+	// When both are empty, all runes are kept.
+	// The synthesized whitespace (see SynthesizeWhitespace) is never removed.
+	SubsetRunes []RuneRange
+
+	// SubsetFrom is a list of text corpus file glob patterns (like "locales/*.json").
+	// For JSON files, only the string values are scanned.
+	SubsetFrom []string
+
 	DebugPrint func(message string)
 
 	MissingGlyphAction MissingGlyphAction
+
+	// SynthesizeWhitespace enables the whitespace glyphs synthesis.
+	// Space, no-break space and tab glyphs are added to the font
+	// unless they're defined in the data-dir.
+	// These glyphs have no ink, only the advance.
+	SynthesizeWhitespace bool
+
+	// SpaceWidth is a synthesized space advance for size=1 font (in pixels).
+	// Other sizes get a proportionally scaled value.
+	// A zero value makes the space as wide as the glyph cell.
+	SpaceWidth int
+
+	// TabSize is a synthesized tab width measured in spaces.
+	// A zero value means 4.
+	TabSize int
+
+	// ZeroWidthIgnorables makes the control characters and
+	// the default-ignorable code points (ZWJ, ZWSP, variation selectors, soft hyphen, etc.)
+	// resolve to an invisible zero-advance glyph unless they're defined in the data-dir.
+	ZeroWidthIgnorables bool
+
+	// ComposeRanges enables the precomposed runes synthesis (é, Ž, Ё, ...).
+	// Missing runes from these ranges are built by overlaying
+	// their canonical decomposition glyphs: a base and combining marks.
+	// All of these components should be defined in the font.
+	//
+	// Marks are positioned using the anchors if both base and mark have them.
+	// Otherwise, the mark is drawn at its own cell position.
+	ComposeRanges []RuneRange
+
+	// ComposeMarkOffset is a vertical offset (in size=1 pixels) for the
+	// above marks composed with tall bases (the ones that are taller than 'x').
+	// A zero value means that the offset is equal to the base and 'x' heights difference.
+	// This setting doesn't affect the marks positioned by the anchors.
+	ComposeMarkOffset int
+
+	// SynthesizeBoxDrawing enables the procedural box-drawing (U+2500-U+257F)
+	// and block element (U+2580-U+259F) glyphs.
+	// They're rendered to fit the glyph cell of every font size.
+	SynthesizeBoxDrawing bool
+
+	// SynthesizeBraille enables the procedural braille patterns (U+2800-U+28FF).
+	SynthesizeBraille bool
+
+	// BoxLineWidth is a light box-drawing line width for size=1 font (in pixels).
+	// Other sizes get a proportionally scaled value.
+	// A zero value makes it 1/8 of the glyph cell smallest side.
+	BoxLineWidth int
+
+	// BoxHeavyLineWidth is a heavy box-drawing line width for size=1 font (in pixels).
+	// A zero value makes it twice as wide as a light line.
+	BoxHeavyLineWidth int
+
+	// SynthesizeSmallFigures enables the superscripts (², ⁿ, ...), subscripts (₂, ₓ, ...)
+	// and vulgar fractions (½, ⅜, ...) synthesis.
+	// They're built from the small versions of the digits, signs and letters.
+	// By default, the small versions are the normal glyphs downscaled 2 times.
+	SynthesizeSmallFigures bool
+
+	// SmallFiguresTag is a data-dir tag that contains the hand-drawn small versions
+	// of the glyphs that are used instead of the downscaled ones.
+	// The images are named after the normal-sized runes (e.g. "50.png" for a small '2')
+	// and they should be drawn on the baseline; the synthesis moves them up or down.
+	// This tag is never included into the font itself.
+	SmallFiguresTag string
+}
+
+// RuneRange is an inclusive range of runes.
+type RuneRange struct {
+	Min rune
+	Max rune
+}
+
+func (rr RuneRange) String() string {
+	return fmt.Sprintf("%#x-%#x", rr.Min, rr.Max)
 }
 
 type MissingGlyphAction int
@@ -28,6 +196,7 @@ const (
 	EmptyMaskOnMissingGlyph MissingGlyphAction = iota
 	StubOnMissingGlyph
 	PanicOnMissingGlyph
+	FallbackOnMissingGlyph
 )
 
 func (g MissingGlyphAction) String() string {
@@ -38,6 +207,8 @@ func (g MissingGlyphAction) String() string {
 		return "stub"
 	case PanicOnMissingGlyph:
 		return "panic"
+	case FallbackOnMissingGlyph:
+		return "fallback"
 	default:
 		return "?"
 	}
@@ -47,11 +218,52 @@ type GenerationResult struct {
 	Warnings []string
 
 	FontInfo FontInfo
+
+	// Synthesized lists the runes that were generated
+	// instead of being loaded from the data-dir.
+	Synthesized []RuneInfo
+
+	// MissingRunes lists the runes that are used in the SubsetFrom corpus,
+	// but are not defined in the font.
+	MissingRunes []RuneInfo
+
+	// Bitmaps describes the generated bitmap data, one entry per font segment.
+	Bitmaps []BitmapInfo
+}
+
+// BitmapInfo describes the bitmap data of a font segment.
+type BitmapInfo struct {
+	Size float64
+
+	// Tag is empty for the core segment that holds the stub and zero-width glyphs.
+	Tag string
+
+	// RawSize is the data size in bytes before encoding.
+	RawSize int
+
+	// CropSavings are the numbers of encoded data bytes that are saved
+	// by storing the glyphs cropped to their ink rectangles, for every codec.
+	// They're reported even if Config.CropGlyphs is disabled.
+	// The values can be negative for the tiny glyphs, since the cropped
+	// glyphs have an extra rectangle header.
+	CropSavings map[BitmapCodec]int
+
+	// EncodedSizes are the data sizes in bytes for every codec,
+	// so the most suitable one can be selected.
+	// The Config.Codec size is the one that is used.
+	EncodedSizes map[BitmapCodec]int
 }
 
 type FontInfo struct {
 	Runes []RuneInfo
 
+	// Aliases lists the runes that re-use other rune glyphs.
+	Aliases []AliasInfo
+
+	// Fallbacks lists the missing runes that are resolved to their closest glyphs
+	// (see FallbackOnMissingGlyph). They're not included into Aliases.
+	Fallbacks []AliasInfo
+
 	Sizes []float64
 
 	Date time.Time
@@ -63,6 +275,13 @@ type RuneInfo struct {
 	Tag         string
 }
 
+type AliasInfo struct {
+	Value             rune
+	StringValue       string
+	Target            rune
+	TargetStringValue string
+}
+
 func Generate(config Config) (GenerationResult, error) {
 	g := newGenerator(config)
 	return g.Generate()
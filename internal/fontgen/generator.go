@@ -2,7 +2,6 @@ package fontgen
 
 import (
 	"bytes"
-	"compress/gzip"
 	"fmt"
 	"go/format"
 	"image"
@@ -10,9 +9,15 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode"
+
+	"github.com/quasilyte/bitfontier/fontrt"
 )
 
 type generator struct {
@@ -21,7 +26,13 @@ type generator struct {
 	font     *bitmapFont
 	warnings []string
 
-	info FontInfo
+	info        FontInfo
+	synthesized []RuneInfo
+	missing     []RuneInfo
+	bitmaps     []BitmapInfo
+
+	// corpus is the SubsetFrom runes set, see loadCorpus.
+	corpus map[rune]bool
 }
 
 func newGenerator(config Config) *generator {
@@ -41,6 +52,13 @@ func (g *generator) Generate() (GenerationResult, error) {
 		{"prepare outdir", g.prepareOutdir},
 		{"parse font", g.parseFont},
 		{"validate font", g.validateFont},
+		{"synthesize whitespace", g.synthesizeWhitespace},
+		{"synthesize procedural glyphs", g.synthesizeProcedural},
+		{"synthesize small figures", g.synthesizeSmallFigures},
+		{"synthesize composites", g.synthesizeComposites},
+		{"resolve aliases", g.resolveAliases},
+		{"resolve fallbacks", g.resolveFallbacks},
+		{"subset runes", g.subsetRunes},
 		{"process font", g.processFont},
 		{"create bitmap", g.createBitmap},
 		{"create package", g.createPackage},
@@ -55,6 +73,9 @@ func (g *generator) Generate() (GenerationResult, error) {
 
 	result.Warnings = g.warnings
 	result.FontInfo = g.info
+	result.Synthesized = g.synthesized
+	result.MissingRunes = g.missing
+	result.Bitmaps = g.bitmaps
 	return result, nil
 }
 
@@ -72,6 +93,54 @@ func (g *generator) validateConfig() error {
 	if g.config.OutDir == "" {
 		g.config.OutDir = g.config.ResultPackage
 	}
+	if g.config.SpaceWidth < 0 {
+		return fmt.Errorf("SpaceWidth can't be negative")
+	}
+	if g.config.TabSize < 0 {
+		return fmt.Errorf("TabSize can't be negative")
+	}
+	if g.config.TabSize == 0 {
+		g.config.TabSize = 4
+	}
+	if g.config.BoxLineWidth < 0 {
+		return fmt.Errorf("BoxLineWidth can't be negative")
+	}
+	if g.config.BoxHeavyLineWidth < 0 {
+		return fmt.Errorf("BoxHeavyLineWidth can't be negative")
+	}
+	for _, rr := range g.config.SubsetRunes {
+		if rr.Min > rr.Max {
+			return fmt.Errorf("invalid SubsetRunes range: %v", rr)
+		}
+	}
+	for i, tag := range g.config.TagPriority {
+		if slices.Contains(g.config.TagPriority[:i], tag) {
+			return fmt.Errorf("TagPriority: duplicated %q tag", tag)
+		}
+	}
+	for _, rr := range g.config.ComposeRanges {
+		if rr.Min > rr.Max {
+			return fmt.Errorf("invalid ComposeRanges range: %v", rr)
+		}
+	}
+	if g.config.Codec < 0 || g.config.Codec >= numCodecs {
+		return fmt.Errorf("unexpected Codec value: %d", g.config.Codec)
+	}
+	if g.config.Mapping < 0 || g.config.Mapping >= numMappings {
+		return fmt.Errorf("unexpected Mapping value: %d", g.config.Mapping)
+	}
+	if g.config.PageSize < 0 {
+		return fmt.Errorf("PageSize can't be negative")
+	}
+	if g.config.PageCacheSize < 0 {
+		return fmt.Errorf("PageCacheSize can't be negative")
+	}
+	if g.config.PageCacheSize != 0 && g.config.PageSize == 0 {
+		return fmt.Errorf("PageCacheSize requires PageSize to be set")
+	}
+	if g.config.SharedRuntime && !g.config.BinaryTables {
+		return fmt.Errorf("SharedRuntime requires BinaryTables to be set")
+	}
 
 	return nil
 }
@@ -100,14 +169,10 @@ func (g *generator) validateFont() error {
 		return fmt.Errorf("can't find size=1 images")
 	}
 
-	if g.config.MissingGlyphAction == StubOnMissingGlyph {
-		for _, sf := range g.font.Sized {
-			sf.NeedsStub = true
-		}
-	}
-
 	for _, sf := range g.font.Sized {
-		set := map[rune]string{}
+		if err := g.resolveTagOverrides(sf); err != nil {
+			return err
+		}
 		for _, r := range sf.Runes {
 			switch r.Value {
 			case '.':
@@ -121,10 +186,14 @@ func (g *generator) validateFont() error {
 			if b.Dx() != sf.GlyphWidth || b.Dy() != sf.GlyphHeight {
 				return fmt.Errorf("%s: found %dx%d image size, expected %dx%d", r, b.Dx(), b.Dy(), sf.GlyphWidth, sf.GlyphHeight)
 			}
-			if prevTag, ok := set[r.Value]; ok {
-				return fmt.Errorf("%s: duplicated rune, previously defined at %q", r, prevTag)
+		}
+
+		for r, img := range sf.SmallFigures {
+			b := img.Bounds()
+			if b.Dx() != sf.GlyphWidth || b.Dy() != sf.GlyphHeight {
+				return fmt.Errorf("%.2f/%s/%v(%q): found %dx%d image size, expected %dx%d",
+					sf.Size, g.config.SmallFiguresTag, r, r, b.Dx(), b.Dy(), sf.GlyphWidth, sf.GlyphHeight)
 			}
-			set[r.Value] = r.Tag
 		}
 
 		if sf.DotImage == nil {
@@ -168,12 +237,12 @@ func (g *generator) validateFont() error {
 		}
 		for r, tag := range size1runes {
 			if _, ok := runes[r]; !ok {
-				sf.NeedsStub = true
 				br := bitmapRune{
 					Value:    r,
 					IsStub:   true,
 					Tag:      tag,
 					Size:     sf.Size,
+					Advance:  sf.GlyphWidth,
 					ImgIndex: -1,
 				}
 				sf.Runes = append(sf.Runes, br)
@@ -185,6 +254,97 @@ func (g *generator) validateFont() error {
 	return nil
 }
 
+// resolveTagOverrides removes the runes that are overridden by the higher priority tags.
+// A rune that is defined in several tags is an error unless all of them
+// are listed in the tags priority list.
+func (g *generator) resolveTagOverrides(sf *sizedBitmapFont) error {
+	priorityList := g.config.TagPriority
+	if len(priorityList) == 0 {
+		priorityList = g.config.Tags
+	}
+
+	winners := make(map[rune]int, len(sf.Runes))
+	for i, r := range sf.Runes {
+		j, ok := winners[r.Value]
+		if !ok {
+			winners[r.Value] = i
+			continue
+		}
+		prev := sf.Runes[j]
+		prevPriority := slices.Index(priorityList, prev.Tag)
+		priority := slices.Index(priorityList, r.Tag)
+		if prevPriority == -1 || priority == -1 {
+			return fmt.Errorf("%s: duplicated rune, previously defined at %q", r, prev.Tag)
+		}
+		winner, loser := r, prev
+		if prevPriority > priority {
+			winner, loser = prev, r
+		} else {
+			winners[r.Value] = i
+		}
+		g.warnings = append(g.warnings, fmt.Sprintf("%s: overrides the rune from %q tag", winner, loser.Tag))
+	}
+	if len(winners) == len(sf.Runes) {
+		return nil
+	}
+
+	runes := make([]bitmapRune, 0, len(winners))
+	for i, r := range sf.Runes {
+		if winners[r.Value] == i {
+			runes = append(runes, r)
+		}
+	}
+	sf.Runes = runes
+	return nil
+}
+
+func (g *generator) synthesizeWhitespace() error {
+	if !g.config.SynthesizeWhitespace {
+		return nil
+	}
+
+	for _, sf := range g.font.Sized {
+		spaceWidth := sf.GlyphWidth
+		if i := sf.findRune(' '); i != -1 && !sf.Runes[i].IsStub {
+			// A hand-drawn space defines the tab width too.
+			spaceWidth = sf.Runes[i].Advance
+		} else if g.config.SpaceWidth != 0 {
+			scale := float64(sf.GlyphWidth) / float64(g.font.Size1.GlyphWidth)
+			spaceWidth = int(math.Round(float64(g.config.SpaceWidth) * scale))
+		}
+
+		whitespace := []struct {
+			r       rune
+			advance int
+		}{
+			{' ', spaceWidth},
+			{'\u00a0', spaceWidth}, // No-break space
+			{'\t', spaceWidth * g.config.TabSize},
+		}
+		blank := image.NewNRGBA(image.Rectangle{
+			Max: image.Pt(sf.GlyphWidth, sf.GlyphHeight),
+		})
+		for _, ws := range whitespace {
+			if i := sf.findRune(ws.r); i != -1 && !sf.Runes[i].IsStub {
+				continue
+			}
+			br := bitmapRune{
+				Value:       ws.r,
+				Img:         blank,
+				Tag:         whitespaceTag,
+				Size:        sf.Size,
+				Advance:     ws.advance,
+				ImgIndex:    -1,
+				Synthesized: true,
+			}
+			sf.setRune(br)
+			g.config.DebugPrint(fmt.Sprintf("%s: synthesized with advance=%d", br, br.Advance))
+		}
+	}
+
+	return nil
+}
+
 func (g *generator) processFont() error {
 	for _, sf := range g.font.Sized {
 		sort.Slice(sf.Runes, func(i, j int) bool {
@@ -205,18 +365,12 @@ func (g *generator) processFont() error {
 	}
 
 	for _, sf := range g.font.Sized {
-		sf.MinRune = rune(math.MaxInt32)
-		sf.MaxRune = rune(math.MinInt32)
-		for _, r := range sf.Runes {
-			sf.MinRune = min(sf.MinRune, r.Value)
-			sf.MaxRune = max(sf.MaxRune, r.Value)
-		}
-	}
-
-	for _, sf := range g.font.Sized {
+		// Only the data-dir images affect the dot position.
+		// The synthesized glyphs (like box-drawing ones) can touch
+		// the cell edges, but they shouldn't move the other glyphs.
 		minX := math.MaxInt
 		for _, r := range sf.Runes {
-			if r.IsStub {
+			if r.IsStub || r.IsAlias || r.Synthesized {
 				continue
 			}
 			w := r.Img.Bounds().Dx()
@@ -243,6 +397,9 @@ func (g *generator) processFont() error {
 			}
 		}
 
+		if minX == math.MaxInt {
+			minX = 0 // No ink at all
+		}
 		sf.DotX = minX
 		sf.DotY = dotY
 	}
@@ -266,10 +423,12 @@ func (g *generator) processFont() error {
 	for _, sf := range g.font.Sized {
 		imgSet := make(map[string]int, len(sf.Runes))
 		for i, r := range sf.Runes {
-			if r.IsStub {
+			if r.IsStub || r.IsAlias {
 				continue
 			}
-			k := imgKey(r.Img)
+			// Glyphs with identical images can still have different advances.
+			// Images are never shared between the segments.
+			k := g.segmentTag(r) + ":" + strconv.Itoa(r.Advance) + ":" + imgKey(r.Img)
 			index, ok := imgSet[k]
 			if ok {
 				g.config.DebugPrint(fmt.Sprintf("%s: re-use image from %s", r, sf.Runes[index]))
@@ -278,169 +437,434 @@ func (g *generator) processFont() error {
 			}
 			imgSet[k] = i
 		}
+
+		// Aliases re-use their target images, just like the duplicates above.
+		index := sf.runeIndexMap()
+		for i, r := range sf.Runes {
+			if !r.IsAlias || r.IsStub {
+				continue
+			}
+			targetIndex := index[r.AliasOf]
+			if target := sf.Runes[targetIndex]; target.ImgIndex != -1 {
+				targetIndex = target.ImgIndex
+			}
+			sf.Runes[i].ImgIndex = targetIndex
+		}
 	}
 
 	return nil
 }
 
 func (g *generator) createBitmap() error {
-	outDir := g.config.OutDir
-
+	segmentTags := make(map[string]string) // Segment name => tag
 	for _, sf := range g.font.Sized {
-		sf.BitmapFilename = sf.SizeTag + ".data.gz"
-
-		numUniqueImages := 0
-		for _, r := range sf.Runes {
-			if r.IsStub {
-				continue
+		sf.Core = newSegment(sf, "", "")
+		sf.TagSegments = sf.TagSegments[:0]
+		segments := map[string]*fontSegment{"": sf.Core}
+		for i, r := range sf.Runes {
+			tag := g.segmentTag(r)
+			seg := segments[tag]
+			if seg == nil {
+				name, err := segmentName(tag)
+				if err != nil {
+					return err
+				}
+				if prevTag, ok := segmentTags[name]; ok && prevTag != tag {
+					return fmt.Errorf("%q and %q tags have the same segment name %q", prevTag, tag, name)
+				}
+				segmentTags[name] = tag
+				seg = newSegment(sf, tag, name)
+				segments[tag] = seg
+				sf.TagSegments = append(sf.TagSegments, seg)
 			}
-			if r.ImgIndex != -1 {
-				continue
+			seg.runeIndices = append(seg.runeIndices, i)
+		}
+		sort.Slice(sf.TagSegments, func(i, j int) bool {
+			return sf.TagSegments[i].Tag < sf.TagSegments[j].Tag
+		})
+
+		if err := g.createSegmentBitmap(sf, sf.Core); err != nil {
+			return err
+		}
+		for _, seg := range sf.TagSegments {
+			if err := g.createSegmentBitmap(sf, seg); err != nil {
+				return err
 			}
-			numUniqueImages++
 		}
-		if sf.NeedsStub {
-			numUniqueImages++
+	}
+
+	return nil
+}
+
+func (g *generator) createSegmentBitmap(sf *sizedBitmapFont, seg *fontSegment) error {
+	// Every segment that has stub runes gets its own stub copy,
+	// so it doesn't depend on other segments being compiled in.
+	// The core segment stub is also used for the runes that are not in the font.
+	needsStub := seg.Tag == "" && g.config.MissingGlyphAction == StubOnMissingGlyph
+	numUniqueImages := 0
+	for _, i := range seg.runeIndices {
+		r := sf.Runes[i]
+		if r.IsStub {
+			needsStub = true
+			continue
+		}
+		if r.ImgIndex != -1 {
+			continue
 		}
-		g.config.DebugPrint(fmt.Sprintf("%.2f: needsStub=%v", sf.Size, sf.NeedsStub))
-		g.config.DebugPrint(fmt.Sprintf("%.2f: %d/%d images are unique", sf.Size, numUniqueImages, len(sf.Runes)))
+		numUniqueImages++
+	}
+	if needsStub {
+		numUniqueImages++
+	}
+	needsZeroWidth := seg.Tag == "" && g.config.ZeroWidthIgnorables
+	if needsZeroWidth {
+		numUniqueImages++
+	}
+	g.config.DebugPrint(fmt.Sprintf("%.2f%s: needsStub=%v", sf.Size, seg, needsStub))
+	g.config.DebugPrint(fmt.Sprintf("%.2f%s: %d/%d images are unique", sf.Size, seg, numUniqueImages, len(seg.runeIndices)))
 
-		numBits := numUniqueImages * int(sf.GlyphBitSize)
-		data := make([]byte, (numBits/8)+1)
-		g.config.DebugPrint(fmt.Sprintf("%.2f: allocated %d bytes", sf.Size, len(data)))
+	numBits := numUniqueImages * int(sf.GlyphBitSize)
+	data := make([]byte, (numBits/8)+1)
+	g.config.DebugPrint(fmt.Sprintf("%.2f%s: allocated %d bytes", sf.Size, seg, len(data)))
 
-		bitIndex := 0
-		dataIndex := 0
-		encodeImage := func(img image.Image) {
-			for y := 0; y < sf.GlyphHeight; y++ {
-				for x := 0; x < sf.GlyphWidth; x++ {
-					clr := img.At(x, y)
-					v := 0
-					if _, _, _, a := clr.RGBA(); a != 0 {
-						v = 1
-					}
-					bytePos := bitIndex / 8
-					byteShift := bitIndex % 8
-					data[bytePos] |= byte(v << byteShift)
-					bitIndex++
+	bitIndex := 0
+	dataIndex := 0
+	seg.GlyphMetrics = make([]glyphMetrics, 0, numUniqueImages)
+	encodeImage := func(img image.Image, advance int) {
+		seg.GlyphMetrics = append(seg.GlyphMetrics, glyphMetrics{
+			InkBounds: inkBounds(img),
+			Advance:   advance,
+		})
+		for y := 0; y < sf.GlyphHeight; y++ {
+			for x := 0; x < sf.GlyphWidth; x++ {
+				clr := img.At(x, y)
+				v := 0
+				if _, _, _, a := clr.RGBA(); a != 0 {
+					v = 1
 				}
+				bytePos := bitIndex / 8
+				byteShift := bitIndex % 8
+				data[bytePos] |= byte(v << byteShift)
+				bitIndex++
 			}
 		}
-		// First add explicitly defined rune images.
-		for i, r := range sf.Runes {
-			if r.ImgIndex != -1 {
-				// Duplicates do not advance bitIndex/dataIndex.
-				continue
-			}
-			if r.IsStub {
-				continue
-			}
-			encodeImage(r.Img)
-			sf.Runes[i].DataIndex = dataIndex
-			dataIndex++
+	}
+	// First add explicitly defined rune images.
+	for _, i := range seg.runeIndices {
+		r := sf.Runes[i]
+		if r.ImgIndex != -1 {
+			// Duplicates do not advance bitIndex/dataIndex.
+			continue
 		}
-		// Then bind the duplicated rune images data indices.
-		for i, r := range sf.Runes {
-			if r.ImgIndex == -1 {
-				continue
-			}
-			sf.Runes[i].DataIndex = sf.Runes[r.ImgIndex].DataIndex
+		if r.IsStub {
+			continue
 		}
-		// If stub is needed, add it as a last data entry.
-		if sf.NeedsStub {
-			encodeImage(sf.StubImage)
-			sf.StubDataIndex = dataIndex
-			dataIndex++
+		encodeImage(r.Img, r.Advance)
+		sf.Runes[i].DataIndex = dataIndex
+		dataIndex++
+	}
+	// Then bind the duplicated rune images data indices.
+	for _, i := range seg.runeIndices {
+		r := sf.Runes[i]
+		if r.ImgIndex == -1 {
+			continue
 		}
-		// Bind all stub runes to that stub data index.
-		for i, r := range sf.Runes {
-			if !r.IsStub {
-				continue
+		sf.Runes[i].DataIndex = sf.Runes[r.ImgIndex].DataIndex
+	}
+	// If stub is needed, add it as a last data entry.
+	if needsStub {
+		encodeImage(sf.StubImage, sf.GlyphWidth)
+		seg.StubDataIndex = dataIndex
+		dataIndex++
+	}
+	// Default-ignorable runes share a blank zero-width glyph.
+	if needsZeroWidth {
+		blank := image.NewNRGBA(image.Rectangle{
+			Max: image.Pt(sf.GlyphWidth, sf.GlyphHeight),
+		})
+		encodeImage(blank, 0)
+		seg.ZeroWidthDataIndex = dataIndex
+		dataIndex++
+	}
+	// Bind all stub runes to that stub data index.
+	for _, i := range seg.runeIndices {
+		if !sf.Runes[i].IsStub {
+			continue
+		}
+		sf.Runes[i].DataIndex = seg.StubDataIndex
+	}
+	g.config.DebugPrint(fmt.Sprintf("%.2f%s: filled %d/%d bytes", sf.Size, seg, bitIndex/8, len(data)))
+
+	// Every page is encoded independently;
+	// the pages are concatenated into a single data blob.
+	chunks := [][]byte{data}
+	croppedChunks := [][]byte{cropData(data, sf.GlyphWidth, sf.GlyphHeight, 0, numUniqueImages)}
+	if g.config.PageSize != 0 && numUniqueImages > g.config.PageSize {
+		seg.PageSize = g.config.PageSize
+		seg.PageCacheSize = g.config.PageCacheSize
+		chunks = chunks[:0]
+		croppedChunks = croppedChunks[:0]
+		for first := 0; first < numUniqueImages; first += seg.PageSize {
+			n := min(seg.PageSize, numUniqueImages-first)
+			chunks = append(chunks, pageData(data, int(sf.GlyphBitSize), first, n))
+			croppedChunks = append(croppedChunks, cropData(data, sf.GlyphWidth, sf.GlyphHeight, first, n))
+		}
+		g.config.DebugPrint(fmt.Sprintf("%.2f%s: %d pages", sf.Size, seg, len(chunks)))
+	}
+
+	encodeChunks := func(codec BitmapCodec, chunks [][]byte, cropped bool) (blob []byte, offsets []int, err error) {
+		offsets = []int{0}
+		for _, chunk := range chunks {
+			encodedChunk, err := g.encodeData(codec, chunk, cropped)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%.2f%s: %v: %w", sf.Size, seg, codec, err)
 			}
-			sf.Runes[i].DataIndex = sf.StubDataIndex
+			blob = append(blob, encodedChunk...)
+			offsets = append(offsets, len(blob))
 		}
-		g.config.DebugPrint(fmt.Sprintf("%.2f: filled %d/%d bytes", sf.Size, bitIndex/8, len(data)))
+		return blob, offsets, nil
+	}
 
-		var compressed bytes.Buffer
-		gzw := gzip.NewWriter(&compressed)
-		if _, err := gzw.Write(data); err != nil {
-			return fmt.Errorf("%.2f: %w", sf.Size, err)
+	// All codecs are tried to report their sizes.
+	// The cropping savings are reported even if it's disabled.
+	info := BitmapInfo{
+		Size:         sf.Size,
+		Tag:          seg.Tag,
+		CropSavings:  make(map[BitmapCodec]int, numCodecs),
+		EncodedSizes: make(map[BitmapCodec]int, numCodecs),
+	}
+	var encoded []byte
+	var offsets []int
+	for codec := BitmapCodec(0); codec < numCodecs; codec++ {
+		blob, chunkOffsets, err := encodeChunks(codec, chunks, false)
+		if err != nil {
+			return err
+		}
+		croppedBlob, croppedOffsets, err := encodeChunks(codec, croppedChunks, true)
+		if err != nil {
+			return err
 		}
-		if err := gzw.Flush(); err != nil {
-			return fmt.Errorf("%.2f: %w", sf.Size, err)
+		info.CropSavings[codec] = len(blob) - len(croppedBlob)
+		if g.config.CropGlyphs {
+			blob, chunkOffsets = croppedBlob, croppedOffsets
 		}
-		if err := gzw.Close(); err != nil {
-			return fmt.Errorf("%.2f: %w", sf.Size, err)
+		info.EncodedSizes[codec] = len(blob)
+		if codec == g.config.Codec {
+			encoded = blob
+			offsets = chunkOffsets
 		}
+	}
+	g.config.DebugPrint(fmt.Sprintf("%.2f%s: cropping saves %d bytes with %v", sf.Size, seg, info.CropSavings[g.config.Codec], g.config.Codec))
+	if g.config.CropGlyphs {
+		chunks = croppedChunks
+	}
+	for _, chunk := range chunks {
+		info.RawSize += len(chunk)
+	}
+	g.bitmaps = append(g.bitmaps, info)
+	g.config.DebugPrint(fmt.Sprintf("%.2f%s: %d bytes encoded with %v", sf.Size, seg, len(encoded), g.config.Codec))
 
-		if err := os.WriteFile(filepath.Join(outDir, sf.BitmapFilename), compressed.Bytes(), 0o644); err != nil {
-			return fmt.Errorf("%.2f: %w", sf.Size, err)
-		}
+	if seg.PageSize != 0 {
+		seg.PageOffsets = offsets
+	}
+	if g.config.InlineData {
+		seg.InlineData = quoteData(encoded)
+		return nil
+	}
+	seg.BitmapFilename += g.config.Codec.fileExt()
+	if err := os.WriteFile(filepath.Join(g.config.OutDir, seg.BitmapFilename), encoded, 0o644); err != nil {
+		return fmt.Errorf("%.2f%s: %w", sf.Size, seg, err)
 	}
 
 	return nil
 }
 
+// pageData copies the bits of n glyphs starting from the first one.
+// The result is byte-aligned, so it can be used as a standalone atlas.
+func pageData(data []byte, glyphBitSize, first, n int) []byte {
+	numBits := n * glyphBitSize
+	page := make([]byte, (numBits+7)/8)
+	offset := first * glyphBitSize
+	for i := 0; i < numBits; i++ {
+		j := offset + i
+		bit := (data[j/8] >> (j % 8)) & 0b1
+		page[i/8] |= bit << (i % 8)
+	}
+	return page
+}
+
 func (g *generator) createPackage() error {
-	maxRune := rune(math.MinInt32)
+	maxGlyphSize := 0
 	for _, sf := range g.font.Sized {
-		maxRune = max(maxRune, sf.MaxRune)
+		maxGlyphSize = max(maxGlyphSize, sf.GlyphWidth, sf.GlyphHeight)
+		for _, seg := range sf.segments() {
+			for _, m := range seg.GlyphMetrics {
+				maxGlyphSize = max(maxGlyphSize, m.Advance)
+			}
+		}
 	}
 
 	data := &templateData{
-		PkgName:     g.config.ResultPackage,
-		Fonts:       g.font.Sized,
-		OnMissing:   g.config.MissingGlyphAction.String(),
-		CompactRune: maxRune < math.MaxUint16,
-	}
-	mappingElemSize := 8
-	if data.CompactRune {
-		mappingElemSize = 4
-	}
-
-	g.config.DebugPrint(fmt.Sprintf("compactRune=%v maxRune=%v", data.CompactRune, maxRune))
-
-	// The rune mapping will use a binary search.
-	// Glyph() is the only method that requires this mapping
-	// and its results are usually cached.
-	// Therefore, we can trade some of the execution speed
-	// for a smaller mapping data structure.
-	// Using a LUT or other approach may increase the mapping
-	// speed at the cost of extra memory/code size.
-	//
+		PkgName:             g.config.ResultPackage,
+		Fonts:               g.font.Sized,
+		OnMissing:           g.config.MissingGlyphAction.String(),
+		CompactMetrics:      maxGlyphSize <= math.MaxUint8,
+		ZeroWidthIgnorables: g.config.ZeroWidthIgnorables,
+		SplitTags:           g.config.SplitTags,
+		Tags:                g.fontTags(),
+		InlineData:          g.config.InlineData,
+		CroppedBitmaps:      g.config.CropGlyphs,
+		SharedRuntime:       g.config.SharedRuntime,
+		RuntimeVersion:      fontrt.Version,
+		DecodeFunc:          g.config.Codec.decodeFunc(),
+	}
+
+	g.config.DebugPrint(fmt.Sprintf("compactMetrics=%v maxGlyphSize=%v", data.CompactMetrics, maxGlyphSize))
+
+	for _, sf := range g.font.Sized {
+		for _, seg := range sf.segments() {
+			// An empty segment range never matches any rune.
+			seg.MinRune = 0
+			seg.MaxRune = -1
+			if len(seg.runeIndices) != 0 {
+				seg.MinRune = sf.Runes[seg.runeIndices[0]].Value
+				seg.MaxRune = sf.Runes[seg.runeIndices[len(seg.runeIndices)-1]].Value
+			}
+		}
+	}
+	// The same rune mapping strategy is used for the entire package,
+	// so the runtime lookup is specialized for it.
 	// The exact mapping method should not concern the users.
+	mapping := g.createRuneMappings(data)
+
 	for _, sf := range g.font.Sized {
-		mapping := []runeAndIndex{}
-		for _, r := range sf.Runes {
-			mapping = append(mapping, runeAndIndex{
-				Rune:  r.Value,
-				Index: r.DataIndex,
-			})
-		}
-		data.RuneMappings = append(data.RuneMappings, &runeMapping{
-			Slice:      mapping,
-			SizeApprox: len(mapping) * mappingElemSize,
-		})
+		for _, seg := range sf.segments() {
+			for _, i := range seg.runeIndices {
+				r := sf.Runes[i]
+				if r.Anchors == nil {
+					continue
+				}
+				e := anchorsEntry{
+					Rune:   r.Value,
+					Top:    r.Anchors.Top,
+					Bottom: r.Anchors.Bottom,
+				}
+				var flags []string
+				if e.Top != nil {
+					flags = append(flags, "anchorTop")
+					e.flagBits |= anchorTop
+				}
+				if e.Bottom != nil {
+					flags = append(flags, "anchorBottom")
+					e.flagBits |= anchorBottom
+				}
+				if r.Anchors.MarkTop != nil {
+					flags = append(flags, "anchorMarkTop")
+					e.flagBits |= anchorMarkTop
+					e.Mark = r.Anchors.MarkTop
+				}
+				if r.Anchors.MarkBottom != nil {
+					flags = append(flags, "anchorMarkBottom")
+					e.flagBits |= anchorMarkBottom
+					e.Mark = r.Anchors.MarkBottom
+				}
+				if len(flags) == 0 {
+					continue
+				}
+				e.Flags = strings.Join(flags, "|")
+				seg.Anchors = append(seg.Anchors, e)
+			}
+			g.config.DebugPrint(fmt.Sprintf("%.2f%s: %d runes have anchors", sf.Size, seg, len(seg.Anchors)))
+		}
 	}
 
-	var buf bytes.Buffer
-	if err := fontfaceTemplate.Execute(&buf, data); err != nil {
+	if g.config.BinaryTables {
+		if err := g.createTables(mapping); err != nil {
+			return err
+		}
+	}
+
+	fontface := fontfaceTemplate
+	if g.config.SharedRuntime {
+		fontface = sharedFontfaceTemplate
+		for _, sf := range g.font.Sized {
+			for _, seg := range sf.segments() {
+				seg.SharedRuntime = true
+			}
+		}
+	}
+	if err := g.writeTemplate("fontface.go", fontface, data); err != nil {
 		return err
 	}
 
-	pretty, err := format.Source(buf.Bytes())
-	if err != nil {
+	if !g.config.SplitTags {
+		return nil
+	}
+
+	// Every tag gets a file with all its sized segments.
+	tagFiles := make(map[string]*tagSegmentTemplateData)
+	var tagFileList []*tagSegmentTemplateData
+	for _, sf := range g.font.Sized {
+		for _, seg := range sf.TagSegments {
+			f := tagFiles[seg.Tag]
+			if f == nil {
+				f = &tagSegmentTemplateData{
+					PkgName:    g.config.ResultPackage,
+					Tag:        seg.Tag,
+					Name:       seg.Name,
+					BuildTag:   seg.BuildTag,
+					InlineData: g.config.InlineData,
+
+					SharedRuntime: g.config.SharedRuntime,
+				}
+				tagFiles[seg.Tag] = f
+				tagFileList = append(tagFileList, f)
+			}
+			f.Segments = append(f.Segments, seg)
+		}
+	}
+	for _, f := range tagFileList {
+		filename := "tag_" + f.Name + "_segment.go"
+		if err := g.writeTemplate(filename, tagSegmentTemplate, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fontTags returns the data-dir tags that have their own segments.
+// Virtual tags are not included: their segments are always loaded.
+func (g *generator) fontTags() []string {
+	var tags []string
+	for _, seg := range g.font.Size1.TagSegments {
+		if !strings.HasPrefix(seg.Tag, "@") {
+			tags = append(tags, seg.Tag)
+		}
+	}
+	return tags
+}
+
+func (g *generator) writeTemplate(filename string, tmpl *template.Template, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(filepath.Join(g.config.OutDir, "fontface.go"), pretty, 0o644); err != nil {
+	pretty, err := format.Source(buf.Bytes())
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return os.WriteFile(filepath.Join(g.config.OutDir, filename), pretty, 0o644)
 }
 
 func (g *generator) copyLibFiles() error {
+	if g.config.SharedRuntime {
+		// The runtime is imported instead.
+		return nil
+	}
+
 	fontimplDir := "_libfiles/fontimpl"
 	files, err := libFiles.ReadDir(fontimplDir)
 	if err != nil {
@@ -450,6 +874,10 @@ func (g *generator) copyLibFiles() error {
 		if f.Name() == "stubs.go" {
 			continue
 		}
+		// Only the selected codec is included.
+		if strings.HasPrefix(f.Name(), "codec_") && f.Name() != g.config.Codec.libFile() {
+			continue
+		}
 		code, err := libFiles.ReadFile(filepath.Join(fontimplDir, f.Name()))
 		if err != nil {
 			return err
@@ -466,11 +894,29 @@ func (g *generator) copyLibFiles() error {
 
 func (g *generator) generateInfo() error {
 	for _, r := range g.font.Size1.Runes {
-		g.info.Runes = append(g.info.Runes, RuneInfo{
+		if r.IsAlias {
+			alias := AliasInfo{
+				Value:             r.Value,
+				StringValue:       runeStringValue(r.Value),
+				Target:            r.AliasOf,
+				TargetStringValue: runeStringValue(r.AliasOf),
+			}
+			if r.IsFallback {
+				g.info.Fallbacks = append(g.info.Fallbacks, alias)
+			} else {
+				g.info.Aliases = append(g.info.Aliases, alias)
+			}
+			continue
+		}
+		info := RuneInfo{
 			Value:       r.Value,
-			StringValue: string(r.Value),
+			StringValue: runeStringValue(r.Value),
 			Tag:         r.Tag,
-		})
+		}
+		g.info.Runes = append(g.info.Runes, info)
+		if r.Synthesized {
+			g.synthesized = append(g.synthesized, info)
+		}
 	}
 
 	for _, sf := range g.font.Sized {
@@ -481,3 +927,11 @@ func (g *generator) generateInfo() error {
 
 	return nil
 }
+
+func runeStringValue(r rune) string {
+	if unicode.IsSpace(r) || !unicode.IsGraphic(r) {
+		// Make invisible runes readable.
+		return fmt.Sprintf("U+%04X", r)
+	}
+	return string(r)
+}
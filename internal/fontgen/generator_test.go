@@ -0,0 +1,546 @@
+package fontgen
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testGlyph is a glyph image drawn using '#' for the ink pixels
+// and '.' for the transparent ones.
+type testGlyph []string
+
+func (glyph testGlyph) image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(glyph[0]), len(glyph)))
+	for y, row := range glyph {
+		for x, c := range row {
+			if c == '#' {
+				img.Set(x, y, color.NRGBA{A: 0xff})
+			}
+		}
+	}
+	return img
+}
+
+// scaled returns the glyph with every pixel repeated n times in both directions.
+func (glyph testGlyph) scaled(n int) testGlyph {
+	rows := make(testGlyph, 0, len(glyph)*n)
+	for _, row := range glyph {
+		var scaled strings.Builder
+		for _, c := range row {
+			scaled.WriteString(strings.Repeat(string(c), n))
+		}
+		for i := 0; i < n; i++ {
+			rows = append(rows, scaled.String())
+		}
+	}
+	return rows
+}
+
+func (glyph testGlyph) String() string {
+	return "\n" + strings.Join(glyph, "\n")
+}
+
+func glyphRows(img image.Image) testGlyph {
+	b := img.Bounds()
+	rows := make(testGlyph, 0, b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		var row strings.Builder
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				row.WriteByte('#')
+			} else {
+				row.WriteByte('.')
+			}
+		}
+		rows = append(rows, row.String())
+	}
+	return rows
+}
+
+// testTags maps the data-dir tags to their glyphs.
+type testTags map[string]map[rune]testGlyph
+
+// newTestGlyphs returns a minimal set of 5x8 glyphs
+// that are required to generate a font.
+func newTestGlyphs() map[rune]testGlyph {
+	return map[rune]testGlyph{
+		'.': {
+			".....",
+			".....",
+			".....",
+			".....",
+			".....",
+			".....",
+			"..#..",
+			".....",
+		},
+		'x': {
+			".....",
+			".....",
+			".....",
+			".....",
+			"#...#",
+			".###.",
+			"#...#",
+			".....",
+		},
+	}
+}
+
+// writeTestDataDir creates a data-dir with size=1 images and returns its path.
+func writeTestDataDir(t *testing.T, tags testTags) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeTestSizeDir(t, dir, "1", tags)
+	return dir
+}
+
+// writeTestSizeDir adds the images of the given size to the data-dir.
+func writeTestSizeDir(t *testing.T, dir, size string, tags testTags) {
+	t.Helper()
+
+	for tag, glyphs := range tags {
+		tagDir := filepath.Join(dir, size, tag)
+		if err := os.MkdirAll(tagDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		for r, glyph := range glyphs {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, glyph.image()); err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(tagDir, strconv.Itoa(int(r))+".png")
+			if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// generateTestFont runs all generation steps and returns the generator,
+// so the tests can inspect the processed font.
+func generateTestFont(t *testing.T, config Config) (*generator, GenerationResult) {
+	t.Helper()
+
+	config.ResultPackage = "testfont"
+	config.OutDir = filepath.Join(t.TempDir(), "testfont")
+	g := newGenerator(config)
+	result, err := g.Generate()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	return g, result
+}
+
+func TestSynthesizeWhitespace(t *testing.T) {
+	space := testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".....",
+		".....",
+		".....",
+		".....",
+	}
+	newDataDir := func(t *testing.T, withSpace bool) string {
+		glyphs := newTestGlyphs()
+		if withSpace {
+			glyphs[' '] = space
+		}
+		dir := writeTestDataDir(t, testTags{"latin": glyphs})
+		glyphs2 := make(map[rune]testGlyph, len(glyphs))
+		for r, glyph := range glyphs {
+			glyphs2[r] = glyph.scaled(2)
+		}
+		writeTestSizeDir(t, dir, "2", testTags{"latin": glyphs2})
+		return dir
+	}
+
+	// The advances are listed for size=1 and size=2 fonts,
+	// their glyphs are 5 and 10 pixels wide.
+	tests := []struct {
+		name       string
+		handSpace  bool
+		spaceWidth int
+		tabSize    int
+		space      [2]int
+		tab        [2]int
+	}{
+		{name: "defaults", space: [2]int{5, 10}, tab: [2]int{20, 40}},
+		{name: "space width", spaceWidth: 3, space: [2]int{3, 6}, tab: [2]int{12, 24}},
+		{name: "tab size", spaceWidth: 3, tabSize: 2, space: [2]int{3, 6}, tab: [2]int{6, 12}},
+		{name: "hand-drawn space", handSpace: true, spaceWidth: 3, space: [2]int{5, 10}, tab: [2]int{20, 40}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, _ := generateTestFont(t, Config{
+				DataDir:              newDataDir(t, test.handSpace),
+				SynthesizeWhitespace: true,
+				SpaceWidth:           test.spaceWidth,
+				TabSize:              test.tabSize,
+			})
+
+			for i, sf := range g.font.Sized {
+				findRune := func(r rune) bitmapRune {
+					j := sf.findRune(r)
+					if j == -1 {
+						t.Fatalf("size=%v: %q is not defined", sf.Size, r)
+					}
+					return sf.Runes[j]
+				}
+				checks := []struct {
+					r       rune
+					advance int
+				}{
+					{' ', test.space[i]},
+					{'\u00a0', test.space[i]},
+					{'\t', test.tab[i]},
+				}
+				for _, check := range checks {
+					br := findRune(check.r)
+					if br.Advance != check.advance {
+						t.Fatalf("size=%v: %q: have %d advance, want %d", sf.Size, check.r, br.Advance, check.advance)
+					}
+					// The hand-drawn space is kept as is.
+					wantTag := whitespaceTag
+					if check.r == ' ' && test.handSpace {
+						wantTag = "latin"
+					}
+					if br.Tag != wantTag || br.Synthesized != (wantTag == whitespaceTag) {
+						t.Fatalf("size=%v: %q: have %q tag (synthesized=%v), want %q", sf.Size, check.r, br.Tag, br.Synthesized, wantTag)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		g, _ := generateTestFont(t, Config{DataDir: newDataDir(t, false)})
+		for _, r := range []rune{' ', '\u00a0', '\t'} {
+			if g.font.Size1.findRune(r) != -1 {
+				t.Fatalf("%q is synthesized", r)
+			}
+		}
+	})
+}
+
+func TestSynthesizeWhitespaceError(t *testing.T) {
+	dataDir := writeTestDataDir(t, testTags{"latin": newTestGlyphs()})
+
+	tests := []struct {
+		config Config
+		err    string
+	}{
+		{Config{SpaceWidth: -1}, "SpaceWidth can't be negative"},
+		{Config{TabSize: -1}, "TabSize can't be negative"},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		config.DataDir = dataDir
+		config.SynthesizeWhitespace = true
+		config.ResultPackage = "testfont"
+		config.OutDir = filepath.Join(t.TempDir(), "testfont")
+		_, err := newGenerator(config).Generate()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("have error: %v\nwant error: %s", err, test.err)
+		}
+	}
+}
+
+// size1Rune returns the generated size=1 rune.
+func (g *generator) size1Rune(t *testing.T, r rune) bitmapRune {
+	t.Helper()
+
+	i := g.font.Size1.findRune(r)
+	if i == -1 {
+		t.Fatalf("%v(%q): rune is not defined", r, r)
+	}
+	return g.font.Size1.Runes[i]
+}
+
+func runeInfoValues(infos []RuneInfo) []rune {
+	values := make([]rune, len(infos))
+	for i, info := range infos {
+		values[i] = info.Value
+	}
+	return values
+}
+
+func TestTagPriority(t *testing.T) {
+	baseO := testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"#...#",
+		".###.",
+		".....",
+	}
+	pixelO := testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		"#####",
+		"#...#",
+		"#####",
+		".....",
+	}
+	newDataDir := func(t *testing.T) string {
+		glyphs := newTestGlyphs()
+		glyphs['o'] = baseO
+		return writeTestDataDir(t, testTags{
+			"base":  glyphs,
+			"pixel": {'o': pixelO},
+			"extra": {'o': pixelO},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		tags     []string
+		priority []string
+		winner   string
+	}{
+		{
+			name:     "later tag wins",
+			tags:     []string{"base", "pixel"},
+			priority: []string{"base", "pixel"},
+			winner:   "pixel",
+		},
+		{
+			name:     "priority order",
+			tags:     []string{"base", "pixel"},
+			priority: []string{"pixel", "base"},
+			winner:   "base",
+		},
+		{
+			name:   "tags order",
+			tags:   []string{"base", "pixel"},
+			winner: "pixel",
+		},
+		{
+			name:     "priority overrides tags order",
+			tags:     []string{"pixel", "base"},
+			priority: []string{"base", "pixel"},
+			winner:   "pixel",
+		},
+		{
+			name:     "three tags",
+			priority: []string{"extra", "base", "pixel"},
+			winner:   "pixel",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, result := generateTestFont(t, Config{
+				DataDir:     newDataDir(t),
+				Tags:        test.tags,
+				TagPriority: test.priority,
+			})
+
+			br := g.size1Rune(t, 'o')
+			if br.Tag != test.winner {
+				t.Fatalf("have %q tag, want %q", br.Tag, test.winner)
+			}
+			want := baseO
+			if test.winner != "base" {
+				want = pixelO
+			}
+			if have := glyphRows(br.Img); !slices.Equal(have, want) {
+				t.Fatalf("image:\nhave:%v\nwant:%v", have, want)
+			}
+			for _, info := range result.FontInfo.Runes {
+				if info.Value == 'o' && info.Tag != test.winner {
+					t.Fatalf("fontinfo: have %q tag, want %q", info.Tag, test.winner)
+				}
+			}
+			numOverrides := max(len(test.tags), len(test.priority)) - 1
+			if len(result.Warnings) != numOverrides {
+				t.Fatalf("have %d warnings, want %d: %q", len(result.Warnings), numOverrides, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestTagPriorityError(t *testing.T) {
+	glyphs := newTestGlyphs()
+	glyphs['o'] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"#...#",
+		".###.",
+		".....",
+	}
+	dataDir := writeTestDataDir(t, testTags{
+		"base":  glyphs,
+		"pixel": {'o': glyphs['o']},
+	})
+
+	tests := []struct {
+		priority []string
+		err      string
+	}{
+		{nil, `duplicated rune, previously defined at`},
+		{[]string{"pixel"}, `duplicated rune, previously defined at`},
+		{[]string{"base", "pixel", "base"}, `TagPriority: duplicated "base" tag`},
+	}
+
+	for _, test := range tests {
+		g := newGenerator(Config{
+			DataDir:       dataDir,
+			ResultPackage: "testfont",
+			OutDir:        filepath.Join(t.TempDir(), "testfont"),
+			TagPriority:   test.priority,
+		})
+		_, err := g.Generate()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%q:\nhave error: %v\nwant error: %s", test.priority, err, test.err)
+		}
+	}
+}
+
+func TestTagSegments(t *testing.T) {
+	glyphs := newTestGlyphs()
+	glyphs['o'] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"#...#",
+		".###.",
+		".....",
+	}
+	dataDir := writeTestDataDir(t, testTags{
+		"en": glyphs,
+		"ru": {'о': glyphs['o']},
+	})
+
+	tests := []struct {
+		name        string
+		tagSegments bool
+		splitTags   bool
+		tags        []string
+		numTagFiles int
+	}{
+		{name: "shared segment"},
+		{name: "tag segments", tagSegments: true, tags: []string{"en", "ru"}},
+		{name: "split tags", splitTags: true, tags: []string{"en", "ru"}, numTagFiles: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, _ := generateTestFont(t, Config{
+				DataDir:     dataDir,
+				TagSegments: test.tagSegments,
+				SplitTags:   test.splitTags,
+			})
+
+			var segmentTags []string
+			for _, seg := range g.font.Size1.segments() {
+				segmentTags = append(segmentTags, seg.Tag)
+			}
+			wantSegmentTags := append([]string{""}, test.tags...)
+			if !slices.Equal(segmentTags, wantSegmentTags) {
+				t.Fatalf("segments:\nhave: %q\nwant: %q", segmentTags, wantSegmentTags)
+			}
+			if have := g.fontTags(); !slices.Equal(have, test.tags) {
+				t.Fatalf("font tags:\nhave: %q\nwant: %q", have, test.tags)
+			}
+
+			// The identical images are only shared inside a segment.
+			cyrillicO := g.size1Rune(t, 'о')
+			latinIndex := g.font.Size1.findRune('o')
+			if shared := cyrillicO.ImgIndex == latinIndex; shared != (test.tags == nil) {
+				t.Fatalf("have shared image=%v (ImgIndex=%d)", shared, cyrillicO.ImgIndex)
+			}
+
+			tagFiles, err := filepath.Glob(filepath.Join(g.config.OutDir, "tag_*_segment.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tagFiles) != test.numTagFiles {
+				t.Fatalf("have %d tag files, want %d", len(tagFiles), test.numTagFiles)
+			}
+		})
+	}
+}
+
+func TestCropSavings(t *testing.T) {
+	dataDir := writeTestDataDir(t, testTags{"latin": newTestGlyphs()})
+	_, full := generateTestFont(t, Config{DataDir: dataDir})
+	_, cropped := generateTestFont(t, Config{DataDir: dataDir, CropGlyphs: true})
+
+	// The savings are the encoded sizes difference, they're reported in both modes.
+	for codec := BitmapCodec(0); codec < numCodecs; codec++ {
+		want := full.Bitmaps[0].EncodedSizes[codec] - cropped.Bitmaps[0].EncodedSizes[codec]
+		for _, result := range []GenerationResult{full, cropped} {
+			if have := result.Bitmaps[0].CropSavings[codec]; have != want {
+				t.Fatalf("%v: have %d bytes saved, want %d", codec, have, want)
+			}
+		}
+	}
+}
+
+func TestSharedRuntimeFormats(t *testing.T) {
+	dataDir := writeTestDataDir(t, testTags{"latin": newTestGlyphs()})
+
+	// The shared runtime selects the codec and the cropping using the data first byte.
+	for _, cropGlyphs := range []bool{false, true} {
+		g, _ := generateTestFont(t, Config{
+			DataDir:       dataDir,
+			SharedRuntime: true,
+			BinaryTables:  true,
+			CropGlyphs:    cropGlyphs,
+			Codec:         RLECodec,
+		})
+		data, err := os.ReadFile(filepath.Join(g.config.OutDir, "1_00.data"+RLECodec.fileExt()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := byte(RLECodec)
+		if cropGlyphs {
+			want |= croppedDataFlag
+		}
+		if data[0] != want {
+			t.Fatalf("CropGlyphs=%v: have %#x format byte, want %#x", cropGlyphs, data[0], want)
+		}
+		tables, err := os.ReadFile(filepath.Join(g.config.OutDir, "1_00.tables"+RLECodec.fileExt()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tables[0] != byte(RLECodec) {
+			t.Fatalf("CropGlyphs=%v: have %#x tables format byte, want %#x", cropGlyphs, tables[0], byte(RLECodec))
+		}
+	}
+
+	// The shared runtime can't decode the Go literal tables.
+	g := newGenerator(Config{
+		DataDir:       dataDir,
+		ResultPackage: "testfont",
+		OutDir:        filepath.Join(t.TempDir(), "testfont"),
+		SharedRuntime: true,
+	})
+	_, err := g.Generate()
+	if want := "SharedRuntime requires BinaryTables"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("have error: %v\nwant error: %s", err, want)
+	}
+}
@@ -0,0 +1,285 @@
+package fontgen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MappingStrategy is a rune mapping implementation used in the generated package.
+// The rune mapping resolves the runes into their glyph data indices.
+type MappingStrategy int
+
+const (
+	AutoMapping MappingStrategy = iota
+	RangeMapping
+	LUTMapping
+	PageTableMapping
+
+	numMappings
+)
+
+func (m MappingStrategy) String() string {
+	switch m {
+	case AutoMapping:
+		return "auto"
+	case RangeMapping:
+		return "ranges"
+	case LUTMapping:
+		return "lut"
+	case PageTableMapping:
+		return "pages"
+	default:
+		return "?"
+	}
+}
+
+// runtimeType returns the runtime implementation type name.
+func (m MappingStrategy) runtimeType() string {
+	switch m {
+	case RangeMapping:
+		return "rangeMapping"
+	case LUTMapping:
+		return "lutMapping"
+	case PageTableMapping:
+		return "pageMapping"
+	default:
+		return ""
+	}
+}
+
+// mappingPageBits is a base-2 logarithm of the page table page size.
+// It should be identical to the runtime constant.
+const mappingPageBits = 8
+
+// runeRangeSize is the runtime runeRange struct size.
+const runeRangeSize = 12
+
+// smallTableSize is a lookup table size that is considered
+// to be small enough to be used regardless of the alternatives.
+const smallTableSize = 4 << 10
+
+type runeRangeEntry struct {
+	Start rune
+	Last  rune
+	N     int
+	Index int
+}
+
+// runeMapping holds the rune mapping tables.
+// The LUT and the page tables store the data index plus one,
+// so zero means that the rune is missing.
+//
+// The lookup tables can be huge for the sparse fonts, so only the rune ranges
+// are built right away; the other tables are built by the build method
+// for the selected strategy, their sizes are computed without them.
+type runeMapping struct {
+	runes []runeAndIndex
+
+	ranges []runeRangeEntry
+
+	lutLen        int // MaxRune-MinRune+1
+	numPages      int
+	numUsedPages  int // The pages with at least one rune
+	maxIndexValue int // The max LUT and entries value
+
+	lut []int
+
+	pages   []int
+	entries []int
+}
+
+func newRuneMapping(runes []runeAndIndex) *runeMapping {
+	m := &runeMapping{runes: runes}
+	if len(runes) == 0 {
+		return m
+	}
+
+	const pageSize = 1 << mappingPageBits
+	minRune := runes[0].Rune
+	maxRune := runes[len(runes)-1].Rune
+	lastPage := -1
+	for _, r := range runes {
+		// The runes are sorted, so the used pages are counted on their first rune.
+		if p := int(r.Rune-minRune) / pageSize; p != lastPage {
+			m.numUsedPages++
+			lastPage = p
+		}
+		m.maxIndexValue = max(m.maxIndexValue, r.Index+1)
+
+		if n := len(m.ranges); n != 0 {
+			last := &m.ranges[n-1]
+			if r.Rune == last.Last+1 && r.Index == last.Index+last.N {
+				last.Last++
+				last.N++
+				continue
+			}
+		}
+		m.ranges = append(m.ranges, runeRangeEntry{
+			Start: r.Rune,
+			Last:  r.Rune,
+			N:     1,
+			Index: r.Index,
+		})
+	}
+	m.lutLen = int(maxRune-minRune) + 1
+	m.numPages = m.lutLen/pageSize + 1
+
+	return m
+}
+
+// build creates the strategy lookup tables.
+func (m *runeMapping) build(strategy MappingStrategy) {
+	if len(m.runes) == 0 {
+		return
+	}
+	minRune := m.runes[0].Rune
+
+	switch strategy {
+	case LUTMapping:
+		m.lut = make([]int, m.lutLen)
+		for _, r := range m.runes {
+			m.lut[r.Rune-minRune] = r.Index + 1
+		}
+
+	case PageTableMapping:
+		const pageSize = 1 << mappingPageBits
+		// The first entries page is shared by all empty pages.
+		m.pages = make([]int, m.numPages)
+		m.entries = make([]int, pageSize, (m.numUsedPages+1)*pageSize)
+		for _, r := range m.runes {
+			offset := int(r.Rune - minRune)
+			p := offset / pageSize
+			if m.pages[p] == 0 {
+				m.pages[p] = len(m.entries) / pageSize
+				m.entries = append(m.entries, make([]int, pageSize)...)
+			}
+			m.entries[m.pages[p]*pageSize+offset%pageSize] = r.Index + 1
+		}
+	}
+}
+
+// maxValue returns the max LUT or page tables value.
+func (m *runeMapping) maxValue() int {
+	// The page table values are the used page numbers.
+	return max(m.maxIndexValue, m.numUsedPages)
+}
+
+// size returns the approximate strategy tables size in bytes.
+func (m *runeMapping) size(strategy MappingStrategy, indexSize int) int {
+	switch strategy {
+	case RangeMapping:
+		return len(m.ranges) * runeRangeSize
+	case LUTMapping:
+		return m.lutLen * indexSize
+	case PageTableMapping:
+		if len(m.runes) == 0 {
+			return 0
+		}
+		const pageSize = 1 << mappingPageBits
+		numEntries := (m.numUsedPages + 1) * pageSize
+		return (m.numPages + numEntries) * indexSize
+	default:
+		return 0
+	}
+}
+
+// pickMappingStrategy selects the strategy using the total tables size.
+//
+// The lookup tables are faster than the range table binary search,
+// so they're preferred unless they're much bigger.
+// The dense LUT is a good fit for the small fonts while
+// the page table works better for the big sparse ones.
+func pickMappingStrategy(sizes map[MappingStrategy]int) MappingStrategy {
+	rangesSize := sizes[RangeMapping]
+	if lutSize := sizes[LUTMapping]; lutSize <= smallTableSize || lutSize <= 2*rangesSize {
+		return LUTMapping
+	}
+	if sizes[PageTableMapping] <= 2*rangesSize {
+		return PageTableMapping
+	}
+	return RangeMapping
+}
+
+// formatIndexRows formats the table values as Go source rows.
+func formatIndexRows(values []int) []string {
+	const rowLen = 16
+	var rows []string
+	for len(values) != 0 {
+		n := min(len(values), rowLen)
+		parts := make([]string, n)
+		for i, v := range values[:n] {
+			parts[i] = strconv.Itoa(v)
+		}
+		rows = append(rows, strings.Join(parts, ", ")+",")
+		values = values[n:]
+	}
+	return rows
+}
+
+// createRuneMappings builds the segment rune mappings using
+// the configured strategy (or the auto-selected one) and returns that strategy.
+func (g *generator) createRuneMappings(data *templateData) MappingStrategy {
+	mappings := make(map[*fontSegment]*runeMapping)
+	maxValue := 0
+	for _, sf := range g.font.Sized {
+		for _, seg := range sf.segments() {
+			runes := make([]runeAndIndex, 0, len(seg.runeIndices))
+			for _, i := range seg.runeIndices {
+				r := sf.Runes[i]
+				runes = append(runes, runeAndIndex{
+					Rune:  r.Value,
+					Index: r.DataIndex,
+				})
+			}
+			m := newRuneMapping(runes)
+			mappings[seg] = m
+			maxValue = max(maxValue, m.maxValue())
+		}
+	}
+
+	indexSize := 4
+	data.CompactIndex = maxValue <= math.MaxUint16
+	if data.CompactIndex {
+		indexSize = 2
+	}
+
+	sizes := make(map[MappingStrategy]int)
+	for _, m := range mappings {
+		for strategy := RangeMapping; strategy < numMappings; strategy++ {
+			sizes[strategy] += m.size(strategy, indexSize)
+		}
+	}
+	strategy := g.config.Mapping
+	if strategy == AutoMapping {
+		strategy = pickMappingStrategy(sizes)
+	}
+	data.MappingType = strategy.runtimeType()
+
+	g.config.DebugPrint(fmt.Sprintf("compactIndex=%v maxIndex=%v", data.CompactIndex, maxValue))
+	g.config.DebugPrint(fmt.Sprintf("rune mapping sizes: ranges=%d lut=%d pages=%d, using %v",
+		sizes[RangeMapping], sizes[LUTMapping], sizes[PageTableMapping], strategy))
+
+	for seg, m := range mappings {
+		m.build(strategy)
+		seg.MappingStrategy = strategy.String()
+		seg.MappingLen = m.lutLen
+		seg.MappingSizeApprox = m.size(strategy, indexSize)
+		if g.config.BinaryTables {
+			seg.mapping = m
+			continue
+		}
+		switch strategy {
+		case RangeMapping:
+			seg.RuneRanges = m.ranges
+		case LUTMapping:
+			seg.MappingLUT = formatIndexRows(m.lut)
+		case PageTableMapping:
+			seg.MappingPages = formatIndexRows(m.pages)
+			seg.MappingEntries = formatIndexRows(m.entries)
+		}
+	}
+
+	return strategy
+}
@@ -0,0 +1,52 @@
+package fontgen
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRuneMappingSize(t *testing.T) {
+	tests := [][]runeAndIndex{
+		nil,
+		{{'a', 0}},
+		{{'a', 0}, {'b', 1}, {'c', 2}, {'z', 5}},
+		{{'a', 0}, {'ё', 1}, {'€', 2}, {'😀', 3}},
+		{{0x100, 0}, {0x1ff, 1}, {0x200, 2}, {0x4ff, 0}},
+	}
+
+	for _, runes := range tests {
+		// The tables are built by separate mappings
+		// to check that the sizes don't depend on them.
+		sized := newRuneMapping(runes)
+		lut := newRuneMapping(runes)
+		lut.build(LUTMapping)
+		pages := newRuneMapping(runes)
+		pages.build(PageTableMapping)
+
+		if have, want := sized.size(LUTMapping, 1), len(lut.lut); have != want {
+			t.Fatalf("%q: lut: have %d size, want %d", runes, have, want)
+		}
+		if have, want := sized.size(PageTableMapping, 1), len(pages.pages)+len(pages.entries); have != want {
+			t.Fatalf("%q: pages: have %d size, want %d", runes, have, want)
+		}
+		wantMax := 0
+		if len(runes) != 0 {
+			wantMax = max(slices.Max(lut.lut), slices.Max(pages.pages))
+		}
+		if have := sized.maxValue(); have != wantMax {
+			t.Fatalf("%q: have %d max value, want %d", runes, have, wantMax)
+		}
+
+		for _, r := range runes {
+			if have := lut.lut[r.Rune-runes[0].Rune]; have != r.Index+1 {
+				t.Fatalf("%q: lut[%q]: have %d, want %d", runes, r.Rune, have, r.Index+1)
+			}
+			offset := int(r.Rune - runes[0].Rune)
+			page := pages.pages[offset>>mappingPageBits]
+			entry := pages.entries[page<<mappingPageBits+offset%(1<<mappingPageBits)]
+			if entry != r.Index+1 {
+				t.Fatalf("%q: pages[%q]: have %d, want %d", runes, r.Rune, entry, r.Index+1)
+			}
+		}
+	}
+}
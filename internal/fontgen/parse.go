@@ -2,9 +2,12 @@ package fontgen
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/png"
+	"math"
 	"os"
 	"path/filepath"
 	"slices"
@@ -15,6 +18,15 @@ import (
 type bitmapFont struct {
 	Sized []*sizedBitmapFont
 	Size1 *sizedBitmapFont
+
+	// Aliases and CaseFold are loaded from the aliases file.
+	Aliases  []runeAlias
+	CaseFold bool
+}
+
+type runeAlias struct {
+	Value  rune
+	Target rune
 }
 
 type sizedBitmapFont struct {
@@ -29,8 +41,6 @@ type sizedBitmapFont struct {
 	Index        int
 
 	// Fields below are initialized during the font processing phase.
-	MinRune      rune
-	MaxRune      rune
 	DotX         int
 	DotY         int
 	CapHeight    int
@@ -38,12 +48,19 @@ type sizedBitmapFont struct {
 	ShortSizeTag string
 	SizeTag      string
 	StubImage    *image.NRGBA
-	NeedsStub    bool
+
+	// SmallFigures are loaded from the small figures tag.
+	// The keys are the normal-sized runes these images are made for.
+	SmallFigures map[rune]image.Image
 
 	// Fields below are initialized during bitmap generation phase.
-	BitmapFilename string
+	Core        *fontSegment
+	TagSegments []*fontSegment
+}
 
-	StubDataIndex int
+type glyphMetrics struct {
+	InkBounds image.Rectangle
+	Advance   int
 }
 
 type bitmapRune struct {
@@ -54,6 +71,27 @@ type bitmapRune struct {
 
 	IsStub bool
 
+	// Synthesized is set for the runes that were generated
+	// instead of being loaded from the data-dir.
+	Synthesized bool
+
+	// IsAlias is set for the runes that re-use other rune glyph (AliasOf).
+	// Aliases share the data index with their targets.
+	IsAlias bool
+	AliasOf rune
+
+	// IsFallback is set for the aliases that are added by
+	// the FallbackOnMissingGlyph resolution.
+	IsFallback bool
+
+	// Advance is a glyph width unless it's overridden
+	// by the glyph synthesis (e.g. a tab glyph is wider).
+	Advance int
+
+	// Anchors are loaded from the tag metadata file.
+	// It's nil for runes without any anchors.
+	Anchors *runeAnchors
+
 	// This field later is used to re-use the duplicated images.
 	// For runes that have identical images, this index
 	// will point to the rune that should be used as "original".
@@ -62,6 +100,74 @@ type bitmapRune struct {
 	DataIndex int
 }
 
+// Synthesized glyphs are assigned to the virtual tags.
+// The '@' prefix makes them distinct from the usual data-dir tags.
+const (
+	whitespaceTag  = "@whitespace"
+	composeTag     = "@compose"
+	proceduralTag  = "@procedural"
+	smallFigureTag = "@small"
+)
+
+// findRune returns the index of the r inside sf.Runes or -1 if it's not there.
+func (sf *sizedBitmapFont) findRune(r rune) int {
+	for i := range sf.Runes {
+		if sf.Runes[i].Value == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func (sf *sizedBitmapFont) runeIndexMap() map[rune]int {
+	m := make(map[rune]int, len(sf.Runes))
+	for i, r := range sf.Runes {
+		m[r.Value] = i
+	}
+	return m
+}
+
+// setRune replaces the existing rune definition (a stub, most likely)
+// or adds a new rune to the font.
+func (sf *sizedBitmapFont) setRune(br bitmapRune) {
+	if i := sf.findRune(br.Value); i != -1 {
+		sf.Runes[i] = br
+		return
+	}
+	sf.Runes = append(sf.Runes, br)
+}
+
+// runeAnchors describe how combining marks are positioned over the base glyphs.
+// All points are specified in the glyph cell coordinates.
+//
+// Top and Bottom are the points where above and below marks are attached.
+// A mark glyph can also have these anchors to allow mark stacking.
+//
+// MarkTop (or MarkBottom) is a mark glyph point that is aligned
+// with the base Top (or Bottom) anchor.
+type runeAnchors struct {
+	Top        *image.Point
+	Bottom     *image.Point
+	MarkTop    *image.Point
+	MarkBottom *image.Point
+}
+
+// aliasAs returns a copy of r that is bound to a different rune value.
+func (r bitmapRune) aliasAs(value rune) bitmapRune {
+	alias := r
+	alias.Value = value
+	alias.IsAlias = true
+	alias.AliasOf = r.Value
+	if r.IsAlias {
+		// Aliases are never chained, they point to the original rune.
+		alias.AliasOf = r.AliasOf
+	}
+	alias.Synthesized = false
+	alias.Anchors = nil
+	alias.ImgIndex = -1
+	return alias
+}
+
 func (r bitmapRune) String() string {
 	return fmt.Sprintf("%.2f/%s/%v(%q)", r.Size, r.Tag, r.Value, r.Value)
 }
@@ -81,6 +187,10 @@ func (p *fontParser) Parse() (*bitmapFont, error) {
 		return nil, err
 	}
 	for _, f := range files {
+		if !f.IsDir() {
+			// Font-wide metadata files, like aliases.json.
+			continue
+		}
 		sizeString := f.Name()
 		size, err := strconv.ParseFloat(sizeString, 64)
 		if err != nil {
@@ -98,9 +208,83 @@ func (p *fontParser) Parse() (*bitmapFont, error) {
 		result.Sized = append(result.Sized, sized)
 	}
 
+	if err := p.parseAliases(filepath.Join(p.config.DataDir, aliasesFilename)); err != nil {
+		return nil, fmt.Errorf("%s: %w", aliasesFilename, err)
+	}
+
 	return result, nil
 }
 
+// aliasesFilename is an optional data-dir metadata file that
+// makes some runes re-use the other rune glyphs:
+//
+//	{
+//		"caseFold": true,
+//		"aliases": {"’": "'", "0xab": "<"}
+//	}
+//
+// A rune can be specified either as a single character
+// or as a decimal (or 0x-prefixed hex) rune code.
+//
+// When caseFold is true, the missing letters re-use
+// the glyphs of their other case variant (e.g. 'a' is rendered as 'A').
+const aliasesFilename = "aliases.json"
+
+func (p *fontParser) parseAliases(filename string) error {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var aliasesFile struct {
+		CaseFold bool              `json:"caseFold"`
+		Aliases  map[string]string `json:"aliases"`
+	}
+	if err := json.Unmarshal(data, &aliasesFile); err != nil {
+		return err
+	}
+
+	parseRune := func(s string) (rune, error) {
+		if runes := []rune(s); len(runes) == 1 {
+			return runes[0], nil
+		}
+		v, err := strconv.ParseInt(s, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("parse %q as rune: %w", s, err)
+		}
+		return rune(v), nil
+	}
+
+	p.result.CaseFold = aliasesFile.CaseFold
+	for key, value := range aliasesFile.Aliases {
+		r, err := parseRune(key)
+		if err != nil {
+			return err
+		}
+		target, err := parseRune(value)
+		if err != nil {
+			return err
+		}
+		if r == target {
+			return fmt.Errorf("%v(%q): rune can't be an alias of itself", r, r)
+		}
+		p.result.Aliases = append(p.result.Aliases, runeAlias{Value: r, Target: target})
+	}
+	slices.SortFunc(p.result.Aliases, func(a, b runeAlias) int {
+		return int(a.Value - b.Value)
+	})
+	for i := 1; i < len(p.result.Aliases); i++ {
+		if r := p.result.Aliases[i].Value; r == p.result.Aliases[i-1].Value {
+			return fmt.Errorf("%v(%q): duplicated alias", r, r)
+		}
+	}
+
+	return nil
+}
+
 func (p *fontParser) parseSized(path string, size float64) (*sizedBitmapFont, error) {
 	sized := &sizedBitmapFont{
 		Size: size,
@@ -112,6 +296,19 @@ func (p *fontParser) parseSized(path string, size float64) (*sizedBitmapFont, er
 	}
 	for _, f := range files {
 		tagString := f.Name()
+		if tagString == p.config.SmallFiguresTag {
+			// This tag is not a part of the font, it's only used
+			// to synthesize the superscripts and subscripts.
+			runes, err := p.parseRunes(filepath.Join(path, tagString), tagString, size)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", tagString, err)
+			}
+			sized.SmallFigures = make(map[rune]image.Image, len(runes))
+			for _, r := range runes {
+				sized.SmallFigures[r.Value] = r.Img
+			}
+			continue
+		}
 		if len(p.config.Tags) > 0 && !slices.Contains(p.config.Tags, tagString) {
 			p.config.DebugPrint(fmt.Sprintf("%.2f: skip %q tag", size, tagString))
 			continue
@@ -129,6 +326,9 @@ func (p *fontParser) parseSized(path string, size float64) (*sizedBitmapFont, er
 		}
 		sized.Runes = append(sized.Runes, runes...)
 	}
+	for i := range sized.Runes {
+		sized.Runes[i].Advance = sized.GlyphWidth
+	}
 
 	return sized, nil
 }
@@ -140,6 +340,9 @@ func (p *fontParser) parseRunes(path, tag string, size float64) ([]bitmapRune, e
 	}
 	runes := make([]bitmapRune, 0, len(files))
 	for _, f := range files {
+		if f.Name() == anchorsFilename {
+			continue
+		}
 		runeValueString := strings.TrimSuffix(f.Name(), ".png")
 		runeValue, err := strconv.Atoi(runeValueString)
 		if err != nil {
@@ -162,5 +365,86 @@ func (p *fontParser) parseRunes(path, tag string, size float64) ([]bitmapRune, e
 		})
 	}
 
+	if err := p.parseAnchors(filepath.Join(path, anchorsFilename), runes); err != nil {
+		return nil, fmt.Errorf("%s: %w", anchorsFilename, err)
+	}
+
 	return runes, nil
 }
+
+// anchorsFilename is an optional tag metadata file that
+// maps the rune codes to their anchors:
+//
+//	{
+//		"97": {"top": [3, 2], "bottom": [3, 12]},
+//		"769": {"markTop": [3, 3], "top": [3, 0]}
+//	}
+const anchorsFilename = "anchors.json"
+
+func (p *fontParser) parseAnchors(filename string, runes []bitmapRune) error {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	type jsonAnchors struct {
+		Top        *[2]int `json:"top"`
+		Bottom     *[2]int `json:"bottom"`
+		MarkTop    *[2]int `json:"markTop"`
+		MarkBottom *[2]int `json:"markBottom"`
+	}
+	var anchorsMap map[string]jsonAnchors
+	if err := json.Unmarshal(data, &anchorsMap); err != nil {
+		return err
+	}
+
+	convertPoint := func(pt *[2]int) (*image.Point, error) {
+		if pt == nil {
+			return nil, nil
+		}
+		for _, v := range pt {
+			if v < math.MinInt8 || v > math.MaxInt8 {
+				return nil, fmt.Errorf("%v: coordinate is out of the [%d, %d] range", *pt, math.MinInt8, math.MaxInt8)
+			}
+		}
+		return &image.Point{X: pt[0], Y: pt[1]}, nil
+	}
+
+	for key, a := range anchorsMap {
+		runeValue, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("parse %q as rune value: %w", key, err)
+		}
+		i := slices.IndexFunc(runes, func(r bitmapRune) bool {
+			return r.Value == rune(runeValue)
+		})
+		if i == -1 {
+			return fmt.Errorf("%v(%q): rune is not defined in this tag", runeValue, rune(runeValue))
+		}
+		if a.MarkTop != nil && a.MarkBottom != nil {
+			return fmt.Errorf("%s: markTop and markBottom can't be used together", runes[i])
+		}
+		var anchors runeAnchors
+		points := []struct {
+			dst **image.Point
+			src *[2]int
+		}{
+			{&anchors.Top, a.Top},
+			{&anchors.Bottom, a.Bottom},
+			{&anchors.MarkTop, a.MarkTop},
+			{&anchors.MarkBottom, a.MarkBottom},
+		}
+		for _, pt := range points {
+			*pt.dst, err = convertPoint(pt.src)
+			if err != nil {
+				return fmt.Errorf("%s: %w", runes[i], err)
+			}
+		}
+		runes[i].Anchors = &anchors
+	}
+
+	return nil
+}
@@ -0,0 +1,471 @@
+package fontgen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	boxDrawingMin = 0x2500
+	boxDrawingMax = 0x257f
+	blocksMin     = 0x2580
+	blocksMax     = 0x259f
+	brailleMin    = 0x2800
+	brailleMax    = 0x28ff
+)
+
+// synthesizeProcedural renders the box-drawing, block element and braille glyphs
+// to fit the glyph cell of every font size.
+// The hand-drawn glyphs always take precedence over the procedural ones.
+func (g *generator) synthesizeProcedural() error {
+	var ranges []RuneRange
+	if g.config.SynthesizeBoxDrawing {
+		ranges = append(ranges, RuneRange{Min: boxDrawingMin, Max: blocksMax})
+	}
+	if g.config.SynthesizeBraille {
+		ranges = append(ranges, RuneRange{Min: brailleMin, Max: brailleMax})
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	for _, sf := range g.font.Sized {
+		light, heavy := g.boxLineWidths(sf)
+		index := sf.runeIndexMap()
+		numRendered := 0
+		for _, rr := range ranges {
+			for r := rr.Min; r <= rr.Max; r++ {
+				if i, ok := index[r]; ok && !sf.Runes[i].IsStub {
+					continue
+				}
+				p := newGlyphPainter(sf.GlyphWidth, sf.GlyphHeight, light, heavy)
+				p.draw(r)
+				sf.setRune(bitmapRune{
+					Value:       r,
+					Img:         p.img,
+					Tag:         proceduralTag,
+					Size:        sf.Size,
+					Advance:     sf.GlyphWidth,
+					ImgIndex:    -1,
+					Synthesized: true,
+				})
+				numRendered++
+			}
+		}
+		g.config.DebugPrint(fmt.Sprintf("%.2f: rendered %d procedural glyphs (line widths: %d, %d)", sf.Size, numRendered, light, heavy))
+	}
+
+	return nil
+}
+
+// boxLineWidths returns the light and heavy line widths for the sf font size.
+func (g *generator) boxLineWidths(sf *sizedBitmapFont) (light, heavy int) {
+	scale := float64(sf.GlyphWidth) / float64(g.font.Size1.GlyphWidth)
+	scaled := func(v int) int {
+		return max(1, int(math.Round(float64(v)*scale)))
+	}
+
+	if g.config.BoxLineWidth != 0 {
+		light = scaled(g.config.BoxLineWidth)
+	} else {
+		light = max(1, int(math.Round(float64(min(sf.GlyphWidth, sf.GlyphHeight))/8)))
+	}
+	if g.config.BoxHeavyLineWidth != 0 {
+		heavy = scaled(g.config.BoxHeavyLineWidth)
+	} else {
+		heavy = light * 2
+	}
+	return light, heavy
+}
+
+type glyphPainter struct {
+	img   *image.NRGBA
+	w     int
+	h     int
+	light int
+	heavy int
+}
+
+func newGlyphPainter(w, h, light, heavy int) *glyphPainter {
+	return &glyphPainter{
+		img:   image.NewNRGBA(image.Rect(0, 0, w, h)),
+		w:     w,
+		h:     h,
+		light: light,
+		heavy: heavy,
+	}
+}
+
+func (p *glyphPainter) fill(x0, y0, x1, y1 int) {
+	rect := image.Rect(x0, y0, x1, y1).Intersect(p.img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			p.img.Set(x, y, color.NRGBA{A: 0xff})
+		}
+	}
+}
+
+func (p *glyphPainter) draw(r rune) {
+	switch {
+	case r >= boxDrawingMin && r <= boxDrawingMax:
+		p.drawBox(r)
+	case r >= blocksMin && r <= blocksMax:
+		p.drawBlock(r)
+	case r >= brailleMin && r <= brailleMax:
+		p.drawBraille(r)
+	}
+}
+
+// Box-drawing line styles.
+const (
+	boxNone   = '.'
+	boxLight  = 'l'
+	boxHeavy  = 'h'
+	boxDouble = 'd'
+)
+
+// Box-drawing arm directions, the order matches the boxLines strings.
+const (
+	armUp = iota
+	armRight
+	armDown
+	armLeft
+)
+
+// boxLines describes the U+2500-U+257F glyphs as their arms styles
+// in the "up, right, down, left" order.
+// An empty string marks the runes that are drawn differently (dashes, arcs and diagonals).
+var boxLines = [boxDrawingMax - boxDrawingMin + 1]string{
+	".l.l", ".h.h", "l.l.", "h.h.", "", "", "", "", "", "", "", "", ".ll.", ".hl.", ".lh.", ".hh.", // 2500
+	"..ll", "..lh", "..hl", "..hh", "ll..", "lh..", "hl..", "hh..", "l..l", "l..h", "h..l", "h..h", "lll.", "lhl.", "hll.", "llh.", // 2510
+	"hlh.", "hhl.", "lhh.", "hhh.", "l.ll", "l.lh", "h.ll", "l.hl", "h.hl", "h.lh", "l.hh", "h.hh", ".lll", ".llh", ".hll", ".hlh", // 2520
+	".lhl", ".lhh", ".hhl", ".hhh", "ll.l", "ll.h", "lh.l", "lh.h", "hl.l", "hl.h", "hh.l", "hh.h", "llll", "lllh", "lhll", "lhlh", // 2530
+	"hlll", "llhl", "hlhl", "hllh", "hhll", "llhh", "lhhl", "hhlh", "lhhh", "hlhh", "hhhl", "hhhh", "", "", "", "", // 2540
+	".d.d", "d.d.", ".dl.", ".ld.", ".dd.", "..ld", "..dl", "..dd", "ld..", "dl..", "dd..", "l..d", "d..l", "d..d", "ldl.", "dld.", // 2550
+	"ddd.", "l.ld", "d.dl", "d.dd", ".dld", ".ldl", ".ddd", "ld.d", "dl.l", "dd.d", "ldld", "dldl", "dddd", "", "", "", // 2560
+	"", "", "", "", "...l", "l...", ".l..", "..l.", "...h", "h...", ".h..", "..h.", ".h.l", "l.h.", ".l.h", "h.l.", // 2570
+}
+
+type span struct {
+	from int
+	to   int
+}
+
+// bands returns the line spans across an axis of the given length.
+// A double line is two light lines separated by a light line wide gap.
+func (p *glyphPainter) bands(length int, style byte) []span {
+	if style == boxDouble {
+		from := (length - 3*p.light) / 2
+		return []span{
+			{from, from + p.light},
+			{from + 2*p.light, from + 3*p.light},
+		}
+	}
+	width := p.light
+	if style == boxHeavy {
+		width = p.heavy
+	}
+	from := (length - width) / 2
+	return []span{{from, from + width}}
+}
+
+func (p *glyphPainter) drawBox(r rune) {
+	switch r {
+	case 0x2504, 0x2505, 0x2508, 0x2509, 0x254c, 0x254d:
+		p.drawDashes(r, false)
+		return
+	case 0x2506, 0x2507, 0x250a, 0x250b, 0x254e, 0x254f:
+		p.drawDashes(r, true)
+		return
+	case 0x256d:
+		p.drawArc(1, 1)
+		return
+	case 0x256e:
+		p.drawArc(-1, 1)
+		return
+	case 0x256f:
+		p.drawArc(-1, -1)
+		return
+	case 0x2570:
+		p.drawArc(1, -1)
+		return
+	case 0x2571:
+		p.drawDiagonal(true)
+		return
+	case 0x2572:
+		p.drawDiagonal(false)
+		return
+	case 0x2573:
+		p.drawDiagonal(true)
+		p.drawDiagonal(false)
+		return
+	}
+
+	arms := boxLines[r-boxDrawingMin]
+	for dir := armUp; dir <= armLeft; dir++ {
+		style := arms[dir]
+		if style == boxNone {
+			continue
+		}
+		vertical := dir == armUp || dir == armDown
+		positive := dir == armRight || dir == armDown
+		acrossLength, alongLength := p.h, p.w
+		perpNeg, perpPos := arms[armUp], arms[armDown]
+		if vertical {
+			acrossLength, alongLength = p.w, p.h
+			perpNeg, perpPos = arms[armLeft], arms[armRight]
+		}
+		for i, s := range p.bands(acrossLength, style) {
+			inner := p.innerEdge(style, i, positive, perpNeg, perpPos, alongLength)
+			from, to := 0, inner
+			if positive {
+				from, to = inner, alongLength
+			}
+			if vertical {
+				p.fill(s.from, from, s.to, to)
+			} else {
+				p.fill(from, s.from, to, s.to)
+			}
+		}
+	}
+}
+
+// innerEdge returns the coordinate where the arm line ends near the cell center.
+// The perpendicular arms (perpNeg and perpPos) decide how the lines are joined.
+func (p *glyphPainter) innerEdge(style byte, line int, positive bool, perpNeg, perpPos byte, length int) int {
+	if perpNeg == boxDouble || perpPos == boxDouble {
+		pair := p.bands(length, boxDouble)
+		// The inner edges connect to the nearest line of the perpendicular pair,
+		// the outer ones go through it to form a corner.
+		var inner bool
+		if style == boxDouble {
+			if line == 0 {
+				inner = perpNeg != boxNone
+			} else {
+				inner = perpPos != boxNone
+			}
+		} else {
+			inner = perpNeg != boxNone && perpPos != boxNone
+		}
+		switch {
+		case positive && inner:
+			return pair[1].from
+		case positive:
+			return pair[0].from
+		case inner:
+			return pair[0].to
+		default:
+			return pair[1].to
+		}
+	}
+
+	// Otherwise, the line covers the perpendicular line junction.
+	// Without perpendicular arms, the line ends in the cell center.
+	perpStyle := byte(boxLight)
+	switch {
+	case perpNeg == boxHeavy || perpPos == boxHeavy:
+		perpStyle = boxHeavy
+	case perpNeg == boxNone && perpPos == boxNone && style == boxHeavy:
+		perpStyle = boxHeavy
+	}
+	b := p.bands(length, perpStyle)[0]
+	if positive {
+		return b.from
+	}
+	return b.to
+}
+
+func (p *glyphPainter) drawDashes(r rune, vertical bool) {
+	style := byte(boxLight)
+	numDashes := 2
+	switch r {
+	case 0x2505, 0x2507, 0x2509, 0x250b, 0x254d, 0x254f:
+		style = boxHeavy
+	}
+	switch r {
+	case 0x2504, 0x2505, 0x2506, 0x2507:
+		numDashes = 3
+	case 0x2508, 0x2509, 0x250a, 0x250b:
+		numDashes = 4
+	}
+
+	acrossLength, alongLength := p.h, p.w
+	if vertical {
+		acrossLength, alongLength = p.w, p.h
+	}
+	s := p.bands(acrossLength, style)[0]
+	for i := 0; i < numDashes; i++ {
+		from := i * alongLength / numDashes
+		to := (i + 1) * alongLength / numDashes
+		if to-from > 1 {
+			to -= max(1, (to-from)/3)
+		}
+		if vertical {
+			p.fill(s.from, from, s.to, to)
+		} else {
+			p.fill(from, s.from, to, s.to)
+		}
+	}
+}
+
+// drawArc draws a rounded light corner.
+// The dx and dy signs select the arms: dx=1 is right, dy=1 is down.
+func (p *glyphPainter) drawArc(dx, dy int) {
+	hband := p.bands(p.h, boxLight)[0]
+	vband := p.bands(p.w, boxLight)[0]
+	cx := float64(vband.from) + float64(p.light)/2
+	cy := float64(hband.from) + float64(p.light)/2
+
+	availX := cx
+	if dx > 0 {
+		availX = float64(p.w) - cx
+	}
+	availY := cy
+	if dy > 0 {
+		availY = float64(p.h) - cy
+	}
+	radius := min(availX, availY)
+	centerX := cx + float64(dx)*radius
+	centerY := cy + float64(dy)*radius
+
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			px := float64(x) + 0.5
+			py := float64(y) + 0.5
+			if (px-centerX)*float64(dx) > 0 || (py-centerY)*float64(dy) > 0 {
+				continue
+			}
+			if math.Abs(math.Hypot(px-centerX, py-centerY)-radius) < float64(p.light)/2 {
+				p.fill(x, y, x+1, y+1)
+			}
+		}
+	}
+
+	armX := int(math.Round(centerX))
+	if dx > 0 {
+		p.fill(armX, hband.from, p.w, hband.to)
+	} else {
+		p.fill(0, hband.from, armX, hband.to)
+	}
+	armY := int(math.Round(centerY))
+	if dy > 0 {
+		p.fill(vband.from, armY, vband.to, p.h)
+	} else {
+		p.fill(vband.from, 0, vband.to, armY)
+	}
+}
+
+// drawDiagonal draws a light line between the cell corners.
+// A rising line goes from the lower left corner to the upper right one.
+func (p *glyphPainter) drawDiagonal(rising bool) {
+	steps := max(p.w, p.h)
+	half := float64(p.light) / 2
+	for i := 0; i < steps; i++ {
+		t := (float64(i) + 0.5) / float64(steps)
+		x := t * float64(p.w)
+		y := t * float64(p.h)
+		if rising {
+			y = float64(p.h) - y
+		}
+		x0 := int(math.Floor(x - half))
+		y0 := int(math.Floor(y - half))
+		p.fill(x0, y0, x0+p.light, y0+p.light)
+	}
+}
+
+func (p *glyphPainter) drawBlock(r rune) {
+	eighthsX := func(n int) int {
+		return max(1, int(math.Round(float64(p.w*n)/8)))
+	}
+	eighthsY := func(n int) int {
+		return max(1, int(math.Round(float64(p.h*n)/8)))
+	}
+	halfX := p.w / 2
+	halfY := p.h / 2
+
+	switch {
+	case r == 0x2580: // Upper half
+		p.fill(0, 0, p.w, halfY)
+	case r >= 0x2581 && r <= 0x2588: // Lower eighths, full block
+		p.fill(0, p.h-eighthsY(int(r-0x2580)), p.w, p.h)
+	case r >= 0x2589 && r <= 0x258f: // Left eighths
+		p.fill(0, 0, eighthsX(int(0x2590-r)), p.h)
+	case r == 0x2590: // Right half
+		p.fill(halfX, 0, p.w, p.h)
+	case r >= 0x2591 && r <= 0x2593: // Shades
+		for y := 0; y < p.h; y++ {
+			for x := 0; x < p.w; x++ {
+				var opaque bool
+				switch r {
+				case 0x2591:
+					opaque = x%2 == 0 && y%2 == 0
+				case 0x2592:
+					opaque = (x+y)%2 == 0
+				case 0x2593:
+					opaque = x%2 == 0 || y%2 == 0
+				}
+				if opaque {
+					p.fill(x, y, x+1, y+1)
+				}
+			}
+		}
+	case r == 0x2594: // Upper eighth
+		p.fill(0, 0, p.w, eighthsY(1))
+	case r == 0x2595: // Right eighth
+		p.fill(p.w-eighthsX(1), 0, p.w, p.h)
+	default: // Quadrants
+		const (
+			upperLeft = 1 << iota
+			upperRight
+			lowerLeft
+			lowerRight
+		)
+		quadrants := [...]int{
+			lowerLeft,                           // 2596
+			lowerRight,                          // 2597
+			upperLeft,                           // 2598
+			upperLeft | lowerLeft | lowerRight,  // 2599
+			upperLeft | lowerRight,              // 259A
+			upperLeft | upperRight | lowerLeft,  // 259B
+			upperLeft | upperRight | lowerRight, // 259C
+			upperRight,                          // 259D
+			upperRight | lowerLeft,              // 259E
+			upperRight | lowerLeft | lowerRight, // 259F
+		}[r-0x2596]
+		if quadrants&upperLeft != 0 {
+			p.fill(0, 0, halfX, halfY)
+		}
+		if quadrants&upperRight != 0 {
+			p.fill(halfX, 0, p.w, halfY)
+		}
+		if quadrants&lowerLeft != 0 {
+			p.fill(0, halfY, halfX, p.h)
+		}
+		if quadrants&lowerRight != 0 {
+			p.fill(halfX, halfY, p.w, p.h)
+		}
+	}
+}
+
+// drawBraille draws a 2x4 dots pattern.
+// The rune bits select the dots: bits 0-2 and 6 are the left column,
+// bits 3-5 and 7 are the right column.
+func (p *glyphPainter) drawBraille(r rune) {
+	dots := [8]image.Point{
+		{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}, {0, 3}, {1, 3},
+	}
+	cellW := p.w / 2
+	cellH := p.h / 4
+	dotSize := max(1, min(cellW/2, cellH/2))
+	bits := r - brailleMin
+	for i, dot := range dots {
+		if bits&(1<<i) == 0 {
+			continue
+		}
+		x := dot.X*cellW + (cellW-dotSize)/2
+		y := dot.Y*cellH + (cellH-dotSize)/2
+		p.fill(x, y, x+dotSize, y+dotSize)
+	}
+}
@@ -0,0 +1,209 @@
+package fontgen
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGlyphPainter(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want testGlyph
+	}{
+		{
+			r: 0x2500, // light horizontal
+			want: testGlyph{
+				"......",
+				"......",
+				"......",
+				"######",
+				"......",
+				"......",
+				"......",
+				"......",
+			},
+		},
+		{
+			r: 0x2501, // heavy horizontal
+			want: testGlyph{
+				"......",
+				"......",
+				"......",
+				"######",
+				"######",
+				"......",
+				"......",
+				"......",
+			},
+		},
+		{
+			r: 0x253c, // light cross
+			want: testGlyph{
+				"..#...",
+				"..#...",
+				"..#...",
+				"######",
+				"..#...",
+				"..#...",
+				"..#...",
+				"..#...",
+			},
+		},
+		{
+			r: 0x256c, // double cross
+			want: testGlyph{
+				".#.#..",
+				".#.#..",
+				"##.###",
+				"......",
+				"##.###",
+				".#.#..",
+				".#.#..",
+				".#.#..",
+			},
+		},
+		{
+			r: 0x2588, // full block
+			want: testGlyph{
+				"######",
+				"######",
+				"######",
+				"######",
+				"######",
+				"######",
+				"######",
+				"######",
+			},
+		},
+		{
+			r: 0x2591, // light shade
+			want: testGlyph{
+				"#.#.#.",
+				"......",
+				"#.#.#.",
+				"......",
+				"#.#.#.",
+				"......",
+				"#.#.#.",
+				"......",
+			},
+		},
+		{
+			r: 0x2596, // lower left quadrant
+			want: testGlyph{
+				"......",
+				"......",
+				"......",
+				"......",
+				"###...",
+				"###...",
+				"###...",
+				"###...",
+			},
+		},
+		{
+			r: 0x2801, // braille dot 1
+			want: testGlyph{
+				".#....",
+				"......",
+				"......",
+				"......",
+				"......",
+				"......",
+				"......",
+				"......",
+			},
+		},
+		{
+			r: 0x28ff, // braille all dots
+			want: testGlyph{
+				".#..#.",
+				"......",
+				".#..#.",
+				"......",
+				".#..#.",
+				"......",
+				".#..#.",
+				"......",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		p := newGlyphPainter(6, 8, 1, 2)
+		p.draw(test.r)
+		if have := glyphRows(p.img); !slices.Equal(have, test.want) {
+			t.Fatalf("%U image:\nhave:%v\nwant:%v", test.r, have, test.want)
+		}
+	}
+}
+
+func TestSynthesizeProcedural(t *testing.T) {
+	glyphs := newTestGlyphs()
+	// A hand-drawn glyph always wins over the procedural one.
+	glyphs[0x2500] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		"#####",
+		".....",
+		".....",
+		".....",
+	}
+	g, result := generateTestFont(t, Config{
+		DataDir:              writeTestDataDir(t, testTags{"box": glyphs}),
+		SynthesizeBoxDrawing: true,
+	})
+
+	synthesized := runeInfoValues(result.Synthesized)
+	if want := (blocksMax - boxDrawingMin + 1) - 1; len(synthesized) != want {
+		t.Fatalf("have %d synthesized runes, want %d", len(synthesized), want)
+	}
+	if slices.Contains(synthesized, 0x2500) {
+		t.Fatalf("hand-drawn U+2500 is synthesized")
+	}
+	if slices.ContainsFunc(synthesized, func(r rune) bool { return r >= brailleMin }) {
+		t.Fatalf("braille patterns are synthesized without SynthesizeBraille")
+	}
+	if have := glyphRows(g.size1Rune(t, 0x2500).Img); !slices.Equal(have, glyphs[0x2500]) {
+		t.Fatalf("U+2500 image:\nhave:%v\nwant:%v", have, glyphs[0x2500])
+	}
+	if br := g.size1Rune(t, 0x2502); br.Tag != proceduralTag || br.Advance != 5 {
+		t.Fatalf("U+2502: unexpected tag (%q) or advance (%d)", br.Tag, br.Advance)
+	}
+}
+
+func TestSynthesizeProceduralDotX(t *testing.T) {
+	glyphs := newTestGlyphs()
+	// No data-dir glyph touches the cell left edge.
+	glyphs['x'] = testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".#..#",
+		"..##.",
+		".#..#",
+		".....",
+	}
+	dataDir := writeTestDataDir(t, testTags{"box": glyphs})
+
+	g, _ := generateTestFont(t, Config{
+		DataDir: dataDir,
+	})
+	if g.font.Size1.DotX != 1 {
+		t.Fatalf("have DotX=%d, want 1", g.font.Size1.DotX)
+	}
+
+	// The procedural glyphs fill the whole cell,
+	// but they don't move the dot.
+	g, _ = generateTestFont(t, Config{
+		DataDir:              dataDir,
+		SynthesizeBoxDrawing: true,
+		SynthesizeBraille:    true,
+	})
+	if g.font.Size1.DotX != 1 {
+		t.Fatalf("procedural glyphs: have DotX=%d, want 1", g.font.Size1.DotX)
+	}
+}
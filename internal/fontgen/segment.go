@@ -0,0 +1,133 @@
+package fontgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fontSegment is a part of the sized font that gets its own
+// bitmap file, rune mapping, glyph metrics and anchors.
+//
+// The core segment is always loaded: it holds the stub and zero-width glyphs.
+// Unless TagSegments (or SplitTags) is set, all other runes belong to the core segment too;
+// otherwise, every tag runes are moved to a tag segment that can be excluded
+// during the font allocation (see WithTags) or during the compilation (see SplitTags).
+type fontSegment struct {
+	// Tag and Name are empty for the core segment.
+	Tag  string
+	Name string
+
+	// Ident is a prefix for the segment-related Go identifiers.
+	Ident string
+
+	// BuildTag excludes the segment from the build; it's empty for the core segment.
+	BuildTag string
+
+	SizeTag     string
+	GlyphWidth  int
+	GlyphHeight int
+
+	BitmapFilename string
+	GlyphMetrics   []glyphMetrics // Indexed by the data index
+
+	// InlineData is the encoded bitmap data Go string literal.
+	// It's only used with Config.InlineData, BitmapFilename is used otherwise.
+	InlineData string
+
+	// PageOffsets are the compressed page boundaries inside the bitmap file.
+	// It's empty unless the segment uses the paged storage (see Config.PageSize).
+	PageOffsets   []int
+	PageSize      int
+	PageCacheSize int
+
+	MinRune           rune
+	MaxRune           rune
+	MappingSizeApprox int
+	Anchors           []anchorsEntry
+
+	// MappingStrategy is the rune mapping strategy name, only its tables are filled.
+	// The LUT and the page table rows are preformatted Go source lines.
+	MappingStrategy string
+	MappingLen      int // The LUT length, i.e. MaxRune-MinRune+1
+	RuneRanges      []runeRangeEntry
+	MappingLUT      []string
+	MappingPages    []string
+	MappingEntries  []string
+
+	// BinaryTables makes the mapping, metrics and anchors stored as the encoded
+	// data (see Config.BinaryTables); it's either inlined or stored in TablesFilename.
+	BinaryTables   bool
+	TablesFilename string
+	InlineTables   string
+
+	// SharedRuntime makes the segment created using the fontrt package API.
+	SharedRuntime bool
+
+	StubDataIndex      int
+	ZeroWidthDataIndex int
+
+	// runeIndices are the sized font runes that belong to this segment.
+	runeIndices []int
+
+	// mapping is only kept for the binary tables encoding.
+	mapping *runeMapping
+}
+
+func (seg *fontSegment) String() string {
+	if seg.Tag == "" {
+		return ""
+	}
+	return "/" + seg.Tag
+}
+
+// segments returns the core segment followed by the tag segments.
+func (sf *sizedBitmapFont) segments() []*fontSegment {
+	return append([]*fontSegment{sf.Core}, sf.TagSegments...)
+}
+
+// segmentTag returns a tag of the segment that r belongs to.
+func (g *generator) segmentTag(r bitmapRune) string {
+	if !g.config.SplitTags && !g.config.TagSegments {
+		return ""
+	}
+	return r.Tag
+}
+
+// newSegment creates a segment with the given name; an empty name is used for the core segment.
+func newSegment(sf *sizedBitmapFont, tag, name string) *fontSegment {
+	seg := &fontSegment{
+		Tag:            tag,
+		Name:           name,
+		Ident:          "size" + sf.SizeTag,
+		SizeTag:        sf.SizeTag,
+		GlyphWidth:     sf.GlyphWidth,
+		GlyphHeight:    sf.GlyphHeight,
+		BitmapFilename: sf.SizeTag + ".data",
+		TablesFilename: sf.SizeTag + ".tables",
+	}
+	if name != "" {
+		seg.Ident += "_" + name + "_"
+		seg.BuildTag = "bitfont_no" + name
+		seg.BitmapFilename = sf.SizeTag + "_" + name + ".data"
+		seg.TablesFilename = sf.SizeTag + "_" + name + ".tables"
+	}
+	return seg
+}
+
+// segmentName converts a tag into a name that can be used inside
+// Go identifiers, build tags and filenames.
+// Virtual tags lose their "@" prefix: "@compose" becomes "compose".
+func segmentName(tag string) (string, error) {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimPrefix(tag, "@"))
+	if name == "" {
+		return "", fmt.Errorf("%q: can't make a segment name out of this tag", tag)
+	}
+	return name, nil
+}
@@ -0,0 +1,269 @@
+package fontgen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type smallFigureKind int
+
+const (
+	smallSuperscript smallFigureKind = iota
+	smallSubscript
+	smallFraction
+)
+
+// smallFigureRanges lists the runes that can be built from the small figures.
+// All of them have a compatibility decomposition to the normal-sized runes.
+var smallFigureRanges = []struct {
+	RuneRange
+	kind smallFigureKind
+}{
+	{RuneRange{0xb2, 0xb3}, smallSuperscript},     // ² ³
+	{RuneRange{0xb9, 0xb9}, smallSuperscript},     // ¹
+	{RuneRange{0xbc, 0xbe}, smallFraction},        // ¼ ½ ¾
+	{RuneRange{0x2070, 0x207f}, smallSuperscript}, // ⁰ ⁱ ⁴ ... ⁿ
+	{RuneRange{0x2080, 0x209c}, smallSubscript},   // ₀ ... ₜ
+	{RuneRange{0x2150, 0x215f}, smallFraction},    // ⅐ ... ⅟
+	{RuneRange{0x2189, 0x2189}, smallFraction},    // ↉
+}
+
+// smallGlyph is a small figure image cropped to its ink bounds.
+// The top is specified relative to the baseline.
+type smallGlyph struct {
+	img *image.NRGBA
+	top int
+}
+
+// synthesizeSmallFigures builds the superscripts, subscripts and vulgar fractions
+// from the small versions of the normal glyphs (digits, signs and some letters).
+//
+// The small glyphs are taken from the SmallFiguresTag images if they're available;
+// otherwise, the normal glyphs are downscaled 2 times: every 2x2 pixels block
+// becomes an opaque pixel if any of its pixels is opaque, so no strokes are lost.
+func (g *generator) synthesizeSmallFigures() error {
+	if !g.config.SynthesizeSmallFigures {
+		return nil
+	}
+
+	// The size=1 font decides which runes can be synthesized,
+	// just like in composites synthesis.
+	var candidates []rune
+	size1index := g.font.Size1.runeIndexMap()
+	for _, rr := range smallFigureRanges {
+		for r := rr.Min; r <= rr.Max; r++ {
+			if i, ok := size1index[r]; ok && !g.font.Size1.Runes[i].IsStub {
+				continue
+			}
+			if _, ok := g.smallFigure(g.font.Size1, size1index, r, rr.kind); ok {
+				candidates = append(candidates, r)
+			}
+		}
+	}
+	g.config.DebugPrint(fmt.Sprintf("%d small figures can be synthesized", len(candidates)))
+
+	for _, sf := range g.font.Sized {
+		index := sf.runeIndexMap()
+		for _, r := range candidates {
+			if i, ok := index[r]; ok && !sf.Runes[i].IsStub {
+				continue
+			}
+			br := bitmapRune{
+				Value:       r,
+				Tag:         smallFigureTag,
+				Size:        sf.Size,
+				Advance:     sf.GlyphWidth,
+				ImgIndex:    -1,
+				Synthesized: true,
+			}
+			img, ok := g.smallFigure(sf, index, r, smallFigureKindOf(r))
+			if !ok {
+				br.IsStub = true
+				sf.setRune(br)
+				g.warnings = append(g.warnings, fmt.Sprintf("%s: some small figures are missing, using a placeholder image", br))
+				continue
+			}
+			br.Img = img
+			sf.setRune(br)
+		}
+	}
+
+	return nil
+}
+
+func smallFigureKindOf(r rune) smallFigureKind {
+	for _, rr := range smallFigureRanges {
+		if r >= rr.Min && r <= rr.Max {
+			return rr.kind
+		}
+	}
+	panic(fmt.Sprintf("%v(%q) is not a small figure", r, r))
+}
+
+func (g *generator) smallFigure(sf *sizedBitmapFont, index map[rune]int, r rune, kind smallFigureKind) (*image.NRGBA, bool) {
+	decomposed := norm.NFKD.String(string(r))
+	if kind == smallFraction {
+		numerator, denominator, ok := strings.Cut(decomposed, "⁄")
+		if !ok {
+			return nil, false
+		}
+		return g.smallFraction(sf, index, numerator, denominator)
+	}
+
+	runes := []rune(decomposed)
+	if len(runes) != 1 || runes[0] == r {
+		return nil, false
+	}
+	glyph, ok := g.smallGlyph(sf, index, runes[0])
+	if !ok {
+		return nil, false
+	}
+
+	baseline := inkBounds(sf.DotImage).Max.Y
+	raise, lower := g.smallFigureShifts(sf, index, runes[0])
+	dst := image.NewNRGBA(image.Rect(0, 0, sf.GlyphWidth, sf.GlyphHeight))
+	x := (sf.GlyphWidth - glyph.img.Bounds().Dx()) / 2
+	y := baseline + glyph.top
+	if kind == smallSuperscript {
+		y -= raise
+	} else {
+		y += lower
+	}
+	drawSmallGlyph(dst, glyph, x, y)
+	return dst, true
+}
+
+// smallFraction draws the superscript-like numerator in the top left corner
+// and the denominator that sits on the baseline in the bottom right corner.
+// They're separated by a diagonal fraction slash.
+func (g *generator) smallFraction(sf *sizedBitmapFont, index map[rune]int, numerator, denominator string) (*image.NRGBA, bool) {
+	baseline := inkBounds(sf.DotImage).Max.Y
+
+	layout := func(s string) ([]smallGlyph, int, bool) {
+		var glyphs []smallGlyph
+		width := 0
+		for _, c := range s {
+			glyph, ok := g.smallGlyph(sf, index, c)
+			if !ok {
+				return nil, 0, false
+			}
+			if width != 0 {
+				width++ // A 1 pixel gap between the figures
+			}
+			width += glyph.img.Bounds().Dx()
+			glyphs = append(glyphs, glyph)
+		}
+		return glyphs, width, width <= sf.GlyphWidth
+	}
+	numeratorGlyphs, _, ok := layout(numerator)
+	if !ok {
+		return nil, false
+	}
+	denominatorGlyphs, denominatorWidth, ok := layout(denominator)
+	if !ok {
+		return nil, false
+	}
+
+	p := newGlyphPainter(sf.GlyphWidth, sf.GlyphHeight, 1, 1)
+	p.drawDiagonal(true)
+	dst := p.img
+
+	raise, _ := g.smallFigureShifts(sf, index, '0')
+	x := 0
+	for _, glyph := range numeratorGlyphs {
+		drawSmallGlyph(dst, glyph, x, baseline+glyph.top-raise)
+		x += glyph.img.Bounds().Dx() + 1
+	}
+	x = sf.GlyphWidth - denominatorWidth
+	for _, glyph := range denominatorGlyphs {
+		drawSmallGlyph(dst, glyph, x, baseline+glyph.top)
+		x += glyph.img.Bounds().Dx() + 1
+	}
+
+	return dst, true
+}
+
+// smallFigureShifts returns the superscript raise and the subscript lower offsets.
+// The superscript digits are aligned with the normal digits top,
+// the subscript ones go below the baseline by a quarter of the digit height.
+func (g *generator) smallFigureShifts(sf *sizedBitmapFont, index map[rune]int, r rune) (raise, lower int) {
+	// Use the digits as a reference for all small figures if possible.
+	ref := r
+	if i, ok := index['0']; ok && !sf.Runes[i].IsStub {
+		ref = '0'
+	}
+	refHeight := sf.GlyphHeight
+	if i, ok := index[ref]; ok && !sf.Runes[i].IsStub {
+		refHeight = inkBounds(sf.Runes[i].Img).Dy()
+	}
+	smallHeight := refHeight / 2
+	if glyph, ok := g.smallGlyph(sf, index, ref); ok {
+		smallHeight = glyph.img.Bounds().Dy()
+	}
+
+	baseline := inkBounds(sf.DotImage).Max.Y
+	raise = refHeight - smallHeight
+	lower = min((smallHeight+2)/4, sf.GlyphHeight-baseline)
+	return raise, lower
+}
+
+// smallGlyph returns the small version of the r glyph.
+func (g *generator) smallGlyph(sf *sizedBitmapFont, index map[rune]int, r rune) (smallGlyph, bool) {
+	baseline := inkBounds(sf.DotImage).Max.Y
+
+	if img, ok := sf.SmallFigures[r]; ok {
+		ink := inkBounds(img)
+		if ink.Empty() {
+			return smallGlyph{}, false
+		}
+		dst := image.NewNRGBA(image.Rect(0, 0, ink.Dx(), ink.Dy()))
+		for y := ink.Min.Y; y < ink.Max.Y; y++ {
+			for x := ink.Min.X; x < ink.Max.X; x++ {
+				dst.Set(x-ink.Min.X, y-ink.Min.Y, img.At(x, y))
+			}
+		}
+		return smallGlyph{img: dst, top: ink.Min.Y - baseline}, true
+	}
+
+	i, ok := index[r]
+	if !ok || sf.Runes[i].IsStub {
+		return smallGlyph{}, false
+	}
+	src := sf.Runes[i].Img
+	ink := inkBounds(src)
+	if ink.Empty() {
+		return smallGlyph{}, false
+	}
+
+	// The pixel blocks are aligned to the baseline,
+	// so all glyphs are downscaled consistently.
+	// The coordinates above the baseline are negative,
+	// an arithmetic shift rounds them down.
+	top := (ink.Min.Y - baseline) >> 1
+	bottom := (ink.Max.Y-1-baseline)>>1 + 1
+	dst := image.NewNRGBA(image.Rect(0, 0, (ink.Dx()+1)/2, bottom-top))
+	for y := ink.Min.Y; y < ink.Max.Y; y++ {
+		for x := ink.Min.X; x < ink.Max.X; x++ {
+			if _, _, _, a := src.At(x, y).RGBA(); a == 0 {
+				continue
+			}
+			dst.Set((x-ink.Min.X)/2, (y-baseline)>>1-top, color.NRGBA{A: 0xff})
+		}
+	}
+	return smallGlyph{img: dst, top: top}, true
+}
+
+func drawSmallGlyph(dst *image.NRGBA, glyph smallGlyph, x, y int) {
+	b := glyph.img.Bounds()
+	for dy := 0; dy < b.Dy(); dy++ {
+		for dx := 0; dx < b.Dx(); dx++ {
+			if _, _, _, a := glyph.img.At(dx, dy).RGBA(); a != 0 {
+				dst.Set(x+dx, y+dy, color.NRGBA{A: 0xff})
+			}
+		}
+	}
+}
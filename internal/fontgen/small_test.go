@@ -0,0 +1,194 @@
+package fontgen
+
+import (
+	"slices"
+	"testing"
+)
+
+func newSmallFiguresTestGlyphs() map[rune]testGlyph {
+	return map[rune]testGlyph{
+		'.': {
+			"......",
+			"......",
+			"......",
+			"......",
+			"......",
+			"......",
+			"......",
+			"......",
+			"..#...",
+			"......",
+		},
+		'0': {
+			"......",
+			".####.",
+			".#..#.",
+			".#..#.",
+			".#..#.",
+			".#..#.",
+			".#..#.",
+			".#..#.",
+			".####.",
+			"......",
+		},
+		'1': {
+			"......",
+			"...#..",
+			"..##..",
+			"...#..",
+			"...#..",
+			"...#..",
+			"...#..",
+			"...#..",
+			"..###.",
+			"......",
+		},
+		'2': {
+			"......",
+			".####.",
+			"....#.",
+			"....#.",
+			".####.",
+			".#....",
+			".#....",
+			".#....",
+			".####.",
+			"......",
+		},
+		'3': {
+			"......",
+			".####.",
+			"....#.",
+			"....#.",
+			"..###.",
+			"....#.",
+			"....#.",
+			"....#.",
+			".####.",
+			"......",
+		},
+	}
+}
+
+func TestSynthesizeSmallFigures(t *testing.T) {
+	tests := []struct {
+		name  string
+		small map[rune]testGlyph
+		want  map[rune]testGlyph
+	}{
+		{
+			name: "downscaled",
+			want: map[rune]testGlyph{
+				'²': {
+					"......",
+					"..##..",
+					"..##..",
+					"..#...",
+					"..##..",
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+				},
+				'₃': {
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+					"..##..",
+					"..##..",
+					"...#..",
+					"..##..",
+				},
+				'½': {
+					".....#",
+					"#...#.",
+					"#...#.",
+					"#..#..",
+					"###...",
+					"..#.##",
+					".#..##",
+					".#..#.",
+					"#...##",
+					"......",
+				},
+			},
+		},
+		{
+			name: "small figures tag",
+			small: map[rune]testGlyph{
+				'2': {
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+					".###..",
+					"..#...",
+					".###..",
+					"......",
+				},
+			},
+			want: map[rune]testGlyph{
+				// Raised to the small '0' top, since it's used as a reference.
+				'²': {
+					"......",
+					"......",
+					".###..",
+					"..#...",
+					".###..",
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+				},
+				'₂': {
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+					"......",
+					".###..",
+					"..#...",
+					".###..",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags := testTags{"digits": newSmallFiguresTestGlyphs()}
+			if test.small != nil {
+				tags["small"] = test.small
+			}
+			g, result := generateTestFont(t, Config{
+				DataDir:                writeTestDataDir(t, tags),
+				SynthesizeSmallFigures: true,
+				SmallFiguresTag:        "small",
+			})
+
+			// Only the runes that can be built from 0-3 digits.
+			want := []rune{'²', '³', '¹', '½', '⁰', '₀', '₁', '₂', '₃', '⅒', '⅓', '⅔', '⅟', '↉'}
+			if have := runeInfoValues(result.Synthesized); !slices.Equal(have, want) {
+				t.Fatalf("synthesized:\nhave: %q\nwant: %q", have, want)
+			}
+			if len(result.Warnings) != 0 {
+				t.Fatalf("unexpected warnings: %q", result.Warnings)
+			}
+			for r, want := range test.want {
+				br := g.size1Rune(t, r)
+				if have := glyphRows(br.Img); !slices.Equal(have, want) {
+					t.Fatalf("%q image:\nhave:%v\nwant:%v", r, have, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package fontgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// subsetRunes removes the runes that are not selected by
+// the SubsetRunes ranges and the SubsetFrom text corpus.
+//
+// The synthesized whitespace glyphs are always kept:
+// the corpus never includes the control characters like '\t'.
+//
+// The corpus runes that are not covered by the font
+// are reported as missing.
+func (g *generator) subsetRunes() error {
+	if !g.hasSubset() {
+		return nil
+	}
+
+	corpus, err := g.loadCorpus()
+	if err != nil {
+		return err
+	}
+	keep := func(br bitmapRune) bool {
+		if corpus[br.Value] || br.Tag == whitespaceTag {
+			return true
+		}
+		for _, rr := range g.config.SubsetRunes {
+			if br.Value >= rr.Min && br.Value <= rr.Max {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, sf := range g.font.Sized {
+		runes := sf.Runes[:0]
+		kept := make(map[rune]bool, len(sf.Runes))
+		for _, r := range sf.Runes {
+			if keep(r) {
+				runes = append(runes, r)
+				kept[r.Value] = true
+			}
+		}
+		for i, r := range runes {
+			if r.IsAlias && !kept[r.AliasOf] {
+				// The alias target is not a part of the subset,
+				// so this rune gets its own copy of the image.
+				runes[i].IsAlias = false
+				runes[i].IsFallback = false
+				runes[i].AliasOf = 0
+			}
+		}
+		if len(runes) == 0 {
+			return fmt.Errorf("%.2f: subset has no runes", sf.Size)
+		}
+		g.config.DebugPrint(fmt.Sprintf("%.2f: subset has %d out of %d runes", sf.Size, len(runes), len(sf.Runes)))
+		sf.Runes = runes
+	}
+
+	size1index := g.font.Size1.runeIndexMap()
+	for r := range corpus {
+		if _, ok := size1index[r]; !ok {
+			g.missing = append(g.missing, RuneInfo{
+				Value:       r,
+				StringValue: runeStringValue(r),
+			})
+		}
+	}
+	slices.SortFunc(g.missing, func(a, b RuneInfo) int {
+		return int(a.Value - b.Value)
+	})
+
+	return nil
+}
+
+// hasSubset reports whether the font is reduced to the selected runes.
+func (g *generator) hasSubset() bool {
+	return len(g.config.SubsetRunes) != 0 || len(g.config.SubsetFrom) != 0
+}
+
+// loadCorpus collects the runes used in the SubsetFrom files.
+// For JSON files, only the string values are scanned;
+// other files are scanned as plain UTF-8 text.
+// Control characters (like newlines) are never included.
+//
+// The files are read only once, the result is cached.
+func (g *generator) loadCorpus() (map[rune]bool, error) {
+	if g.corpus != nil {
+		return g.corpus, nil
+	}
+
+	corpus := make(map[rune]bool)
+	addString := func(s string) {
+		for _, r := range s {
+			if r == utf8.RuneError || unicode.IsControl(r) {
+				continue
+			}
+			corpus[r] = true
+		}
+	}
+
+	for _, pattern := range g.config.SubsetFrom {
+		filenames, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("SubsetFrom: %w", err)
+		}
+		if len(filenames) == 0 {
+			return nil, fmt.Errorf("SubsetFrom: no files match %q", pattern)
+		}
+		for _, filename := range filenames {
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			if filepath.Ext(filename) != ".json" {
+				addString(string(data))
+				continue
+			}
+			var v any
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			walkJSONStrings(v, addString)
+		}
+	}
+
+	g.config.DebugPrint(fmt.Sprintf("corpus uses %d runes", len(corpus)))
+	g.corpus = corpus
+	return corpus, nil
+}
+
+// walkJSONStrings calls visit for every string value inside v.
+// Object keys are not visited: they're usually the message IDs.
+func walkJSONStrings(v any, visit func(s string)) {
+	switch v := v.(type) {
+	case string:
+		visit(v)
+	case []any:
+		for _, elem := range v {
+			walkJSONStrings(elem, visit)
+		}
+	case map[string]any:
+		for _, elem := range v {
+			walkJSONStrings(elem, visit)
+		}
+	}
+}
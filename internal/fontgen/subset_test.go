@@ -0,0 +1,139 @@
+package fontgen
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeTestCorpus(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSubsetRunes(t *testing.T) {
+	corpusDir := writeTestCorpus(t, map[string]string{
+		"a.txt": "x8\n",
+		"b.txt": "éä",
+		// Only the string values are scanned, the keys are ignored.
+		"c.json": `{"o": ["0", {"q": "."}], "n": 10}`,
+		"d.md":   "o",
+	})
+	g, result := generateTestFont(t, Config{
+		DataDir:     newAliasesTestDataDir(t, `{"aliases": {"0": "o", "8": "B"}}`),
+		SubsetRunes: []RuneRange{{Min: 'A', Max: 'Z'}},
+		// The synthesized whitespace is kept even if it's not
+		// in the subset (the corpus can't even have a '\t').
+		SynthesizeWhitespace: true,
+		SubsetFrom: []string{
+			filepath.Join(corpusDir, "*.txt"),
+			filepath.Join(corpusDir, "*.json"),
+		},
+	})
+
+	var runes []rune
+	for _, br := range g.font.Size1.Runes {
+		runes = append(runes, br.Value)
+	}
+	if want := []rune{'\t', ' ', '.', '0', '8', 'B', 'x', '\u00a0'}; !slices.Equal(runes, want) {
+		t.Fatalf("runes:\nhave: %q\nwant: %q", runes, want)
+	}
+
+	if want := []rune{'ä', 'é'}; !slices.Equal(runeInfoValues(result.MissingRunes), want) {
+		t.Fatalf("missing runes:\nhave: %q\nwant: %q", runeInfoValues(result.MissingRunes), want)
+	}
+
+	// The '0' alias target is not a part of the subset,
+	// so it becomes a normal rune with the same image.
+	zero := g.size1Rune(t, '0')
+	if zero.IsAlias || zero.ImgIndex != -1 {
+		t.Fatalf("'0': unexpected alias (IsAlias=%v, ImgIndex=%d)", zero.IsAlias, zero.ImgIndex)
+	}
+	wantZero := testGlyph{
+		".....",
+		".....",
+		".....",
+		".....",
+		".###.",
+		"#...#",
+		".###.",
+		".....",
+	}
+	if have := glyphRows(zero.Img); !slices.Equal(have, wantZero) {
+		t.Fatalf("'0' image:\nhave:%v\nwant:%v", have, wantZero)
+	}
+
+	var aliases []rune
+	for _, a := range result.FontInfo.Aliases {
+		aliases = append(aliases, a.Value)
+	}
+	if want := []rune{'8'}; !slices.Equal(aliases, want) {
+		t.Fatalf("aliases:\nhave: %q\nwant: %q", aliases, want)
+	}
+}
+
+func TestSubsetRunesError(t *testing.T) {
+	corpusDir := writeTestCorpus(t, map[string]string{
+		"bad.json":   `{"a": `,
+		"other.txt":  "ä",
+		"nothing.md": "",
+	})
+
+	tests := []struct {
+		name   string
+		ranges []RuneRange
+		from   []string
+		err    string
+	}{
+		{
+			name: "no matches",
+			from: []string{filepath.Join(corpusDir, "*.yaml")},
+			err:  `SubsetFrom: no files match`,
+		},
+		{
+			name: "bad pattern",
+			from: []string{filepath.Join(corpusDir, "[")},
+			err:  `SubsetFrom: syntax error in pattern`,
+		},
+		{
+			name: "bad json",
+			from: []string{filepath.Join(corpusDir, "*.json")},
+			err:  `bad.json: unexpected end of JSON input`,
+		},
+		{
+			name: "empty subset",
+			from: []string{filepath.Join(corpusDir, "*.txt")},
+			err:  `subset has no runes`,
+		},
+		{
+			name:   "bad range",
+			ranges: []RuneRange{{Min: 'z', Max: 'a'}},
+			err:    `invalid SubsetRunes range`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newGenerator(Config{
+				DataDir:       newAliasesTestDataDir(t, `{"aliases": {"0": "o", "8": "B"}}`),
+				ResultPackage: "testfont",
+				OutDir:        filepath.Join(t.TempDir(), "testfont"),
+				SubsetRunes:   test.ranges,
+				SubsetFrom:    test.from,
+			})
+			_, err := g.Generate()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("have error: %v\nwant error: %s", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package fontgen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// Anchor flags, they should be identical to the runtime constants.
+const (
+	anchorTop uint8 = 1 << iota
+	anchorBottom
+	anchorMarkTop
+	anchorMarkBottom
+)
+
+// createTables encodes the segment tables as the binary data
+// that is decoded by the runtime loadTables.
+// See the runtime tables.go for the format description.
+func (g *generator) createTables(strategy MappingStrategy) error {
+	for _, sf := range g.font.Sized {
+		for _, seg := range sf.segments() {
+			data := encodeTables(seg, strategy, g.config.SharedRuntime)
+			encoded, err := g.encodeData(g.config.Codec, data, false)
+			if err != nil {
+				return fmt.Errorf("%.2f%s: tables: %w", sf.Size, seg, err)
+			}
+			g.config.DebugPrint(fmt.Sprintf("%.2f%s: %d bytes of tables (%d before encoding)", sf.Size, seg, len(encoded), len(data)))
+
+			seg.BinaryTables = true
+			if g.config.InlineData {
+				seg.InlineTables = quoteData(encoded)
+				continue
+			}
+			seg.TablesFilename += g.config.Codec.fileExt()
+			if err := os.WriteFile(filepath.Join(g.config.OutDir, seg.TablesFilename), encoded, 0o644); err != nil {
+				return fmt.Errorf("%.2f%s: %w", sf.Size, seg, err)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeTables encodes the segment tables; the shared runtime
+// also needs the mapping strategy since it supports all of them.
+func encodeTables(seg *fontSegment, strategy MappingStrategy, shared bool) []byte {
+	var data []byte
+	putUint := func(v int) {
+		data = binary.AppendUvarint(data, uint64(v))
+	}
+	putInt := func(v int) {
+		data = binary.AppendVarint(data, int64(v))
+	}
+	putValues := func(values []int) {
+		putUint(len(values))
+		for _, v := range values {
+			putUint(v)
+		}
+	}
+	putPoint := func(p *image.Point) {
+		data = append(data, byte(int8(p.X)), byte(int8(p.Y)))
+	}
+
+	m := seg.mapping
+	if shared {
+		putUint(int(strategy))
+	}
+	switch strategy {
+	case RangeMapping:
+		putUint(len(m.ranges))
+		end := rune(0)
+		for _, rr := range m.ranges {
+			putUint(int(rr.Start - end))
+			putUint(rr.N)
+			putUint(rr.Index)
+			end = rr.Start + rune(rr.N)
+		}
+	case LUTMapping:
+		putInt(int(seg.MinRune))
+		putValues(m.lut)
+	case PageTableMapping:
+		putInt(int(seg.MinRune))
+		putValues(m.pages)
+		putValues(m.entries)
+	}
+
+	putUint(len(seg.GlyphMetrics))
+	for _, gm := range seg.GlyphMetrics {
+		putUint(gm.InkBounds.Min.X)
+		putUint(gm.InkBounds.Min.Y)
+		putUint(gm.InkBounds.Max.X)
+		putUint(gm.InkBounds.Max.Y)
+		putUint(gm.Advance)
+	}
+
+	putUint(len(seg.Anchors))
+	prevRune := rune(0)
+	for _, a := range seg.Anchors {
+		putUint(int(a.Rune - prevRune))
+		data = append(data, a.flagBits)
+		if a.Top != nil {
+			putPoint(a.Top)
+		}
+		if a.Bottom != nil {
+			putPoint(a.Bottom)
+		}
+		if a.Mark != nil {
+			putPoint(a.Mark)
+		}
+		prevRune = a.Rune
+	}
+
+	return data
+}
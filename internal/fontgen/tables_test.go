@@ -0,0 +1,101 @@
+package fontgen
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/quasilyte/bitfontier/fontrt"
+)
+
+func TestTablesRoundTrip(t *testing.T) {
+	const w, h = 8, 8
+	// Every glyph is a filled ink rectangle, so the runes
+	// can be told apart by their masks.
+	glyphs := []struct {
+		r       rune
+		ink     image.Rectangle
+		advance int
+	}{
+		{'a', image.Rect(1, 3, 6, 8), 7},
+		{'b', image.Rect(0, 0, 5, 8), 6},
+		{'\u0301', image.Rect(2, 0, 4, 2), 6},
+		{'\u0323', image.Rect(2, 6, 3, 7), 6},
+		{'ж', image.Rect(0, 2, 8, 8), 8},
+	}
+	bitmap := make([]byte, len(glyphs)*w*h/8)
+	runes := make([]runeAndIndex, len(glyphs))
+	metrics := make([]glyphMetrics, len(glyphs))
+	for i, glyph := range glyphs {
+		for y := glyph.ink.Min.Y; y < glyph.ink.Max.Y; y++ {
+			for x := glyph.ink.Min.X; x < glyph.ink.Max.X; x++ {
+				bit := i*w*h + y*w + x
+				bitmap[bit/8] |= 1 << (bit % 8)
+			}
+		}
+		runes[i] = runeAndIndex{glyph.r, i}
+		metrics[i] = glyphMetrics{InkBounds: glyph.ink, Advance: glyph.advance}
+	}
+	anchors := []anchorsEntry{
+		{Rune: 'a', Top: &image.Point{3, -1}, Bottom: &image.Point{3, 8}, flagBits: anchorTop | anchorBottom},
+		{Rune: '\u0301', Mark: &image.Point{3, 3}, flagBits: anchorMarkTop},
+		{Rune: '\u0323', Mark: &image.Point{2, 5}, flagBits: anchorMarkBottom},
+	}
+
+	// The marks ink positions in the 'a' cell coordinates:
+	// the mark point is aligned with the 'a' anchor.
+	tests := []struct {
+		text string
+		ink  []image.Rectangle
+	}{
+		{"a", []image.Rectangle{glyphs[0].ink}},
+		{"a\u0301", []image.Rectangle{glyphs[0].ink, image.Rect(2, -4, 4, -2)}},
+		{"a\u0323", []image.Rectangle{glyphs[0].ink, image.Rect(3, 9, 4, 10)}},
+	}
+
+	for _, strategy := range []MappingStrategy{RangeMapping, LUTMapping, PageTableMapping} {
+		t.Run(strategy.String(), func(t *testing.T) {
+			seg := &fontSegment{
+				GlyphWidth:   w,
+				GlyphHeight:  h,
+				MinRune:      'a',
+				MaxRune:      'ж',
+				GlyphMetrics: metrics,
+				Anchors:      anchors,
+				mapping:      newRuneMapping(runes),
+			}
+			face := newRuntimeFont(t, seg, strategy, NoCodec, bitmap)
+
+			for i, glyph := range glyphs {
+				checkRuntimeGlyph(t, face, glyph.r, bitmap, i, w, h)
+				advance, ok := face.GlyphAdvance(glyph.r)
+				if !ok || advance.Round() != glyph.advance {
+					t.Fatalf("%q: have %v advance, want %d", glyph.r, advance, glyph.advance)
+				}
+			}
+			for _, r := range []rune{'c', '\u0300', 'я', 'ж' + 1} {
+				if _, ok := face.GlyphAdvance(r); ok {
+					t.Fatalf("%q: unexpected glyph", r)
+				}
+			}
+
+			// The marks are drawn using the anchors.
+			const cellY = 6
+			for _, test := range tests {
+				dst := image.NewAlpha(image.Rect(0, 0, 20, 20))
+				fontrt.DrawString(face, dst, 0, cellY+h, test.text, color.Opaque)
+				for y := 0; y < 20; y++ {
+					for x := 0; x < 20; x++ {
+						want := false
+						for _, ink := range test.ink {
+							want = want || image.Pt(x, y-cellY).In(ink)
+						}
+						if have := dst.AlphaAt(x, y).A != 0; have != want {
+							t.Fatalf("%q: pixel at %d,%d: have %v, want %v", test.text, x, y, have, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -35,3 +35,19 @@ func measureLetter(img image.Image) (w, h int) {
 
 	return maxX - minX + 1, maxY - minY + 1
 }
+
+// inkBounds returns the rectangle that covers all non-transparent pixels.
+// An empty rectangle is returned for images without any ink.
+func inkBounds(img image.Image) image.Rectangle {
+	var result image.Rectangle
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a == 0 {
+				continue
+			}
+			result = result.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	return result.Sub(bounds.Min)
+}